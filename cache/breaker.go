@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	"github.com/Cotary/go-lib/log"
+	"github.com/Cotary/go-lib/notify"
+)
+
+const (
+	defaultBreakerOpenTime     = 10 * time.Second
+	defaultBreakerMaxDirtyKeys = 10000
+)
+
+// BreakerConfig enables a circuit breaker around the remote layer of a
+// two-level cache. After FailureThreshold consecutive Redis errors the cache
+// runs on the local layer alone: reads fall through to the loader, writes
+// only touch memory, and a single alert is sent through notify. Every
+// OpenTime one call probes Redis; the first success resumes normal operation.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive Redis errors that opens
+	// the breaker. Zero disables the breaker.
+	FailureThreshold int
+	// OpenTime is how long the breaker stays open before probing Redis (default 10s).
+	OpenTime time.Duration
+	// MaxDirtyKeys bounds the keys written locally while Redis was failing
+	// (default 10000). They are deleted from Redis once it recovers so it
+	// does not serve values older than the local ones. Beyond the bound a
+	// versioned remote is flushed instead; otherwise the extra keys are only
+	// logged and Redis may serve stale values until they expire.
+	MaxDirtyKeys int
+}
+
+// circuitState represents the circuit breaker state.
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // normal operation
+	circuitOpen                         // Redis failing, local only
+	circuitHalfOpen                     // one probe in flight
+)
+
+// breaker tracks the health of the remote layer.
+type breaker struct {
+	threshold    int
+	openTime     time.Duration
+	maxDirtyKeys int
+	name         string
+	onRecover    func(ctx context.Context, keys []string, overflow bool) error
+
+	mu          sync.Mutex
+	state       circuitState
+	openedAt    time.Time
+	failures    int
+	alerted     bool
+	dirty       map[string]struct{}
+	overflow    bool
+	recovering  atomic.Bool
+	dirtyExists atomic.Bool
+}
+
+func newBreaker(cfg BreakerConfig, name string, onRecover func(ctx context.Context, keys []string, overflow bool) error) *breaker {
+	openTime := cfg.OpenTime
+	if openTime <= 0 {
+		openTime = defaultBreakerOpenTime
+	}
+	maxDirtyKeys := cfg.MaxDirtyKeys
+	if maxDirtyKeys <= 0 {
+		maxDirtyKeys = defaultBreakerMaxDirtyKeys
+	}
+	return &breaker{
+		threshold:    cfg.FailureThreshold,
+		openTime:     openTime,
+		maxDirtyKeys: maxDirtyKeys,
+		name:         name,
+		onRecover:    onRecover,
+		dirty:        make(map[string]struct{}),
+	}
+}
+
+// allow reports whether a call may use Redis. Once OpenTime has passed, a
+// single caller is let through as the probe.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.openTime {
+			return false
+		}
+		b.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// report records the outcome of a Redis call and reports whether the caller
+// should fall back to the local layer. Cache misses and cancellations by
+// the caller are not Redis failures.
+func (b *breaker) report(ctx context.Context, err error) bool {
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, errAbsent) {
+		if ctx.Err() != nil {
+			b.release()
+			return false
+		}
+		b.failure(ctx, err)
+		return true
+	}
+	b.success(ctx)
+	return false
+}
+
+// release gives up a probe that ended without telling whether Redis is back,
+// so the next call probes again.
+func (b *breaker) release() {
+	b.mu.Lock()
+	if b.state == circuitHalfOpen {
+		b.state = circuitOpen
+	}
+	b.mu.Unlock()
+}
+
+func (b *breaker) success(ctx context.Context) {
+	b.mu.Lock()
+	recovered := b.state != circuitClosed
+	b.state = circuitClosed
+	b.failures = 0
+	b.alerted = false
+	b.mu.Unlock()
+
+	if recovered {
+		log.WithContext(ctx).WithField("cache", b.name).Info("cache: remote layer recovered")
+	}
+	if b.dirtyExists.Load() {
+		b.recover(ctx)
+	}
+}
+
+func (b *breaker) failure(ctx context.Context, err error) {
+	b.mu.Lock()
+	b.failures++
+	opening := b.state == circuitHalfOpen || (b.state == circuitClosed && b.failures >= b.threshold)
+	if opening {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+	alert := opening && !b.alerted
+	if alert {
+		b.alerted = true
+	}
+	b.mu.Unlock()
+
+	if alert {
+		alertErr := fmt.Errorf("cache %q: remote layer unavailable, serving from local cache only: %w", b.name, err)
+		coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+			notify.SendErrMessage(ctx, alertErr)
+		})
+	}
+}
+
+// markDirty remembers keys written only to the local layer.
+func (b *breaker) markDirty(keys ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		if len(b.dirty) >= b.maxDirtyKeys {
+			b.overflow = true
+			break
+		}
+		b.dirty[key] = struct{}{}
+	}
+	b.dirtyExists.Store(true)
+}
+
+// recover hands the dirty keys to onRecover in the background, once at a time.
+func (b *breaker) recover(ctx context.Context) {
+	if !b.recovering.CompareAndSwap(false, true) {
+		return
+	}
+
+	b.mu.Lock()
+	keys := make([]string, 0, len(b.dirty))
+	for key := range b.dirty {
+		keys = append(keys, key)
+	}
+	overflow := b.overflow
+	b.dirty = make(map[string]struct{})
+	b.overflow = false
+	b.dirtyExists.Store(false)
+	b.mu.Unlock()
+
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		defer b.recovering.Store(false)
+		if err := b.onRecover(ctx, keys, overflow); err != nil {
+			// Try again after the next successful call.
+			b.markDirty(keys...)
+			if overflow {
+				b.mu.Lock()
+				b.overflow = true
+				b.mu.Unlock()
+			}
+			log.WithContext(ctx).WithFields(map[string]any{"cache": b.name, "error": err.Error()}).
+				Warn("cache: failed to purge keys written during outage")
+		}
+	})
+}
@@ -3,25 +3,120 @@ package cache
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand/v2"
 	"time"
 )
 
 var ErrNotFound = errors.New("cache: not found")
 
+// errAbsent is returned internally when a key is cached as not existing.
+// Public methods translate it to ErrNotFound.
+var errAbsent = errors.New("cache: cached as absent")
+
+// noExpiry stands in for "never expires" where a duration is required.
+const noExpiry = 100 * 365 * 24 * time.Hour
+
 // LoaderFunc loads a value from the origin source when cache misses.
 type LoaderFunc[T any] func(ctx context.Context, key string) (T, error)
 
+// BatchLoaderFunc loads the values of several keys from the origin source.
+// Keys missing from the returned map are treated as not found.
+type BatchLoaderFunc[T any] func(ctx context.Context, keys []string) (map[string]T, error)
+
 // Cache is the unified cache interface supporting memory, Redis, and two-level caches.
 type Cache[T any] interface {
 	Get(ctx context.Context, key string) (T, error)
 	Set(ctx context.Context, key string, value T, opts ...Option) error
 	Delete(ctx context.Context, key string) error
 	GetOrLoad(ctx context.Context, key string, loader LoaderFunc[T]) (T, error)
+
+	// GetMany returns the cached values of keys; missing keys are omitted from the result.
+	GetMany(ctx context.Context, keys []string) (map[string]T, error)
+	SetMany(ctx context.Context, items map[string]T, opts ...Option) error
+	DeleteMany(ctx context.Context, keys []string) error
+	// GetOrLoadMany calls loader once with the keys not found in the cache and
+	// caches what it returns. Keys that do not exist are omitted from the result.
+	GetOrLoadMany(ctx context.Context, keys []string, loader BatchLoaderFunc[T]) (map[string]T, error)
+
+	// Stats returns a snapshot of the cache's hit, load and eviction counters.
+	Stats() Stats
 	Close() error
 }
 
-type options struct {
+// NegativeConfig enables caching of "not found" loader results, so repeated
+// lookups of missing keys are answered with ErrNotFound without calling the loader.
+type NegativeConfig struct {
+	// TTL is how long an absent marker is kept. Zero disables negative caching.
 	TTL time.Duration
+	// IsNotFound reports whether a loader error means the key does not exist,
+	// e.g. errors.Is(err, gorm.ErrRecordNotFound). Defaults to errors.Is(err, ErrNotFound).
+	IsNotFound func(error) bool
+	// Marker is the raw value stored in Redis for absent keys. It must never be
+	// produced by the Codec. Defaults to DefaultAbsentMarker.
+	Marker []byte
+}
+
+func (n NegativeConfig) enabled() bool {
+	return n.TTL > 0
+}
+
+func (n NegativeConfig) notFound(err error) bool {
+	if n.IsNotFound != nil {
+		return n.IsNotFound(err)
+	}
+	return errors.Is(err, ErrNotFound)
+}
+
+func (n NegativeConfig) marker() []byte {
+	if len(n.Marker) > 0 {
+		return n.Marker
+	}
+	return DefaultAbsentMarker
+}
+
+// RefreshConfig keeps hot keys loaded via GetOrLoad from all expiring at once.
+type RefreshConfig struct {
+	// Ahead triggers an asynchronous reload once an entry is within this window
+	// of expiry; callers keep getting the current value meanwhile. Zero disables.
+	Ahead time.Duration
+	// StaleTTL keeps a value this long past its expiry. GetOrLoad reloads a
+	// stale entry synchronously and returns the stale value if the loader fails.
+	// Get never returns stale values. Zero disables.
+	StaleTTL time.Duration
+	// Jitter randomizes every TTL by up to ±Jitter (a fraction in [0, 1)) to
+	// spread expirations. Zero disables.
+	Jitter float64
+}
+
+// minJitteredTTL keeps a jittered TTL positive; a non-positive TTL would
+// store the key without expiry in Redis.
+const minJitteredTTL = time.Millisecond
+
+func (r RefreshConfig) validate() error {
+	if r.Jitter < 0 || r.Jitter >= 1 {
+		return fmt.Errorf("cache: Refresh.Jitter must be in [0, 1), got %v", r.Jitter)
+	}
+	return nil
+}
+
+// jitter randomizes ttl by up to ±Jitter, never going below minJitteredTTL.
+func (r RefreshConfig) jitter(ttl time.Duration) time.Duration {
+	if r.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	delta := time.Duration((rand.Float64()*2 - 1) * r.Jitter * float64(ttl))
+	return max(ttl+delta, minJitteredTTL)
+}
+
+// due reports whether an entry with remaining lifetime should be refreshed ahead.
+func (r RefreshConfig) due(remaining time.Duration) bool {
+	return r.Ahead > 0 && remaining <= r.Ahead
+}
+
+type options struct {
+	TTL  time.Duration
+	Tags []string
 }
 
 type Option func(*options)
@@ -32,6 +127,14 @@ func WithTTL(d time.Duration) Option {
 	}
 }
 
+// WithTags labels the written keys so TagInvalidator.InvalidateTag can delete
+// them together. Only the Redis and two-level caches record tags.
+func WithTags(tags ...string) Option {
+	return func(o *options) {
+		o.Tags = append(o.Tags, tags...)
+	}
+}
+
 func applyOptions(opts []Option) options {
 	var o options
 	for _, fn := range opts {
@@ -39,3 +142,24 @@ func applyOptions(opts []Option) options {
 	}
 	return o
 }
+
+// missingKeys returns the keys, deduplicated, that are neither found nor absent.
+func missingKeys[T any](keys []string, found map[string]T, absent []string) []string {
+	skip := make(map[string]struct{}, len(found)+len(absent))
+	for k := range found {
+		skip[k] = struct{}{}
+	}
+	for _, k := range absent {
+		skip[k] = struct{}{}
+	}
+
+	var missing []string
+	for _, k := range keys {
+		if _, ok := skip[k]; ok {
+			continue
+		}
+		skip[k] = struct{}{}
+		missing = append(missing, k)
+	}
+	return missing
+}
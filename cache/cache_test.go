@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Cotary/go-lib/dlock"
+	goredisv9 "github.com/go-redsync/redsync/v4/redis/goredis/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -138,6 +141,251 @@ func TestMemoryCache_GetOrLoad_Singleflight(t *testing.T) {
 func TestMemoryCache_InvalidConfig(t *testing.T) {
 	_, err := NewMemory[string](MemoryConfig{MaxSize: 0})
 	assert.Error(t, err)
+	_, err = NewMemory[string](MemoryConfig{MaxSize: 10, MaxWeight: 1024})
+	assert.Error(t, err, "MaxSize and MaxWeight are exclusive")
+}
+
+func TestMemoryCache_BatchOps(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemory[string](MemoryConfig{MaxSize: 100, DefaultTTL: time.Minute})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.SetMany(ctx, map[string]string{"k1": "v1", "k2": "v2"}))
+
+	got, err := c.GetMany(ctx, []string{"k1", "k2", "k3"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, got)
+
+	require.NoError(t, c.DeleteMany(ctx, []string{"k1", "k2"}))
+	got, err = c.GetMany(ctx, []string{"k1", "k2"})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestMemoryCache_GetOrLoadMany(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemory[string](MemoryConfig{
+		MaxSize:    100,
+		DefaultTTL: time.Minute,
+		Negative:   NegativeConfig{TTL: time.Minute},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.Set(ctx, "k1", "cached"))
+
+	var requested [][]string
+	loader := func(ctx context.Context, keys []string) (map[string]string, error) {
+		requested = append(requested, keys)
+		result := make(map[string]string)
+		for _, k := range keys {
+			if k != "k3" {
+				result[k] = "loaded:" + k
+			}
+		}
+		return result, nil
+	}
+
+	got, err := c.GetOrLoadMany(ctx, []string{"k1", "k2", "k3"}, loader)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k1": "cached", "k2": "loaded:k2"}, got)
+	require.Len(t, requested, 1)
+	assert.ElementsMatch(t, []string{"k2", "k3"}, requested[0])
+
+	// k2 is now cached and k3 is cached as absent
+	got, err = c.GetOrLoadMany(ctx, []string{"k1", "k2", "k3"}, loader)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k1": "cached", "k2": "loaded:k2"}, got)
+	assert.Len(t, requested, 1)
+}
+
+func TestMemoryCache_RefreshAhead(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemory[int32](MemoryConfig{
+		MaxSize:    100,
+		DefaultTTL: 300 * time.Millisecond,
+		Refresh:    RefreshConfig{Ahead: 200 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	var callCount atomic.Int32
+	loader := func(ctx context.Context, key string) (int32, error) {
+		return callCount.Add(1), nil
+	}
+
+	v, err := c.GetOrLoad(ctx, "k1", loader)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), v)
+
+	// Inside the refresh window the current value is served while reloading
+	time.Sleep(150 * time.Millisecond)
+	v, err = c.GetOrLoad(ctx, "k1", loader)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), v)
+
+	assert.Eventually(t, func() bool {
+		v, err := c.Get(ctx, "k1")
+		return err == nil && v == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMemoryCache_StaleOnError(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemory[string](MemoryConfig{
+		MaxSize:    100,
+		DefaultTTL: 100 * time.Millisecond,
+		Refresh:    RefreshConfig{StaleTTL: time.Minute},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.Set(ctx, "k1", "old"))
+	time.Sleep(150 * time.Millisecond)
+
+	// Get never returns stale values
+	_, err = c.Get(ctx, "k1")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	v, err := c.GetOrLoad(ctx, "k1", func(ctx context.Context, key string) (string, error) {
+		return "", errors.New("db down")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "old", v)
+
+	v, err = c.GetOrLoad(ctx, "k1", func(ctx context.Context, key string) (string, error) {
+		return "new", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new", v)
+}
+
+func TestRefreshConfig_Jitter(t *testing.T) {
+	r := RefreshConfig{Jitter: 0.1}
+	for i := 0; i < 100; i++ {
+		d := r.jitter(time.Second)
+		assert.GreaterOrEqual(t, d, 900*time.Millisecond)
+		assert.LessOrEqual(t, d, 1100*time.Millisecond)
+	}
+	assert.Equal(t, time.Second, RefreshConfig{}.jitter(time.Second))
+
+	// A tiny TTL never jitters down to zero or below, which Redis would store without expiry.
+	tiny := RefreshConfig{Jitter: 0.99}
+	for i := 0; i < 100; i++ {
+		assert.GreaterOrEqual(t, tiny.jitter(time.Microsecond), minJitteredTTL)
+	}
+
+	_, err := NewMemory[string](MemoryConfig{MaxSize: 10, Refresh: RefreshConfig{Jitter: 1}})
+	assert.Error(t, err)
+	_, err = NewRedis[string](RedisConfig{Client: redis.NewClient(&redis.Options{}), DefaultTTL: time.Second, Refresh: RefreshConfig{Jitter: -0.1}})
+	assert.Error(t, err)
+}
+
+func TestMemoryCache_Stats(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	events := make(map[EventKind]int)
+	c, err := NewMemory[string](MemoryConfig{
+		MaxSize:    100,
+		DefaultTTL: time.Minute,
+		Hook: HookFunc(func(e Event) {
+			mu.Lock()
+			events[e.Kind]++
+			mu.Unlock()
+		}),
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	loader := func(ctx context.Context, key string) (string, error) {
+		if key == "bad" {
+			return "", errors.New("boom")
+		}
+		return "v", nil
+	}
+
+	_, _ = c.GetOrLoad(ctx, "k1", loader)  // miss + load
+	_, _ = c.GetOrLoad(ctx, "k1", loader)  // hit
+	_, _ = c.Get(ctx, "k2")                // miss
+	_, _ = c.GetOrLoad(ctx, "bad", loader) // miss + failed load
+
+	st := c.Stats()
+	assert.Equal(t, uint64(1), st.Hits)
+	assert.Equal(t, uint64(1), st.LocalHits)
+	assert.Equal(t, uint64(0), st.RemoteHits)
+	assert.Equal(t, uint64(3), st.Misses)
+	assert.Equal(t, uint64(2), st.Loads)
+	assert.Equal(t, uint64(1), st.LoadErrors)
+	assert.InDelta(t, 0.25, st.HitRatio(), 0.001)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, events[EventHit])
+	assert.Equal(t, 3, events[EventMiss])
+	assert.Equal(t, 2, events[EventLoad])
+}
+
+func TestMemoryCache_Stats_Evictions(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemory[string](MemoryConfig{MaxSize: 100, DefaultTTL: 50 * time.Millisecond})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.Set(ctx, "k1", "v1"))
+	time.Sleep(100 * time.Millisecond)
+	_, _ = c.Get(ctx, "k1")
+	c.(*memoryCache[string]).inner.CleanUp()
+
+	assert.Eventually(t, func() bool {
+		return c.Stats().Evictions == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMemoryCache_NegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	errRecordNotFound := errors.New("record not found")
+	c, err := NewMemory[string](MemoryConfig{
+		MaxSize:    100,
+		DefaultTTL: time.Minute,
+		Negative: NegativeConfig{
+			TTL:        100 * time.Millisecond,
+			IsNotFound: func(err error) bool { return errors.Is(err, errRecordNotFound) },
+		},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	var callCount atomic.Int32
+	loader := func(ctx context.Context, key string) (string, error) {
+		callCount.Add(1)
+		return "", errRecordNotFound
+	}
+
+	_, err = c.GetOrLoad(ctx, "missing", loader)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = c.GetOrLoad(ctx, "missing", loader)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, int32(1), callCount.Load())
+
+	_, err = c.Get(ctx, "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Absent marker expires with its own TTL
+	time.Sleep(200 * time.Millisecond)
+	_, err = c.GetOrLoad(ctx, "missing", loader)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, int32(2), callCount.Load())
+
+	// Other loader errors are not cached
+	otherErr := errors.New("timeout")
+	_, err = c.GetOrLoad(ctx, "flaky", func(ctx context.Context, key string) (string, error) {
+		return "", otherErr
+	})
+	assert.True(t, errors.Is(err, otherErr))
+	_, err = c.Get(ctx, "flaky")
+	assert.True(t, errors.Is(err, ErrNotFound))
 }
 
 // ==================== Redis Cache Tests ====================
@@ -282,6 +530,138 @@ func TestRedisCache_InvalidConfig(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRedisCache_NegativeCaching(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	c, err := NewRedis[User](RedisConfig{
+		Client:     rdb,
+		Prefix:     "test:neg",
+		DefaultTTL: time.Minute,
+		Negative:   NegativeConfig{TTL: time.Minute},
+	})
+	require.NoError(t, err)
+	defer rdb.Del(ctx, "test:neg:u404")
+
+	var callCount atomic.Int32
+	loader := func(ctx context.Context, key string) (User, error) {
+		callCount.Add(1)
+		return User{}, ErrNotFound
+	}
+
+	_, err = c.GetOrLoad(ctx, "u404", loader)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = c.GetOrLoad(ctx, "u404", loader)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, int32(1), callCount.Load())
+
+	raw, err := rdb.Get(ctx, "test:neg:u404").Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAbsentMarker, raw)
+
+	_, err = c.Get(ctx, "u404")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestRedisCache_BatchOps(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	c, err := NewRedis[User](RedisConfig{Client: rdb, Prefix: "test:batch", DefaultTTL: time.Minute})
+	require.NoError(t, err)
+	defer rdb.Del(ctx, "test:batch:u1", "test:batch:u2", "test:batch:u3")
+
+	users := map[string]User{"u1": {Name: "alice", Age: 30}, "u2": {Name: "bob", Age: 25}}
+	require.NoError(t, c.SetMany(ctx, users))
+
+	got, err := c.GetMany(ctx, []string{"u1", "u2", "u3"})
+	require.NoError(t, err)
+	assert.Equal(t, users, got)
+
+	var callCount atomic.Int32
+	got, err = c.GetOrLoadMany(ctx, []string{"u1", "u3"}, func(ctx context.Context, keys []string) (map[string]User, error) {
+		callCount.Add(1)
+		assert.Equal(t, []string{"u3"}, keys)
+		return map[string]User{"u3": {Name: "carol", Age: 40}}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]User{"u1": users["u1"], "u3": {Name: "carol", Age: 40}}, got)
+	assert.Equal(t, int32(1), callCount.Load())
+
+	require.NoError(t, c.DeleteMany(ctx, []string{"u1", "u2", "u3"}))
+	got, err = c.GetMany(ctx, []string{"u1", "u2", "u3"})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestRedisCache_StaleOnError(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	c, err := NewRedis[string](RedisConfig{
+		Client:     rdb,
+		Prefix:     "test:stale",
+		DefaultTTL: time.Second,
+		Refresh:    RefreshConfig{StaleTTL: time.Minute},
+	})
+	require.NoError(t, err)
+	defer rdb.Del(ctx, "test:stale:k1")
+
+	require.NoError(t, c.Set(ctx, "k1", "old"))
+	time.Sleep(1100 * time.Millisecond)
+
+	_, err = c.Get(ctx, "k1")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	v, err := c.GetOrLoad(ctx, "k1", func(ctx context.Context, key string) (string, error) {
+		return "", errors.New("db down")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "old", v)
+}
+
+func TestClusterSlot(t *testing.T) {
+	assert.Equal(t, uint16(0x31C3), crc16("123456789"))
+	assert.Equal(t, 12182, clusterSlot("foo"))
+	assert.Equal(t, clusterSlot("user1000"), clusterSlot("{user1000}.following"))
+	assert.Equal(t, clusterSlot("{}.a"), clusterSlot("{}.a"))
+	assert.NotEqual(t, clusterSlot("{}.a"), clusterSlot("{}.b"))
+}
+
+func TestRedisCache_UndecodableIsMiss(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+	defer rdb.Del(ctx, "test:undecodable:u1", "test:undecodable:u2")
+
+	plain, err := NewRedis[User](RedisConfig{Client: rdb, Prefix: "test:undecodable", DefaultTTL: time.Minute})
+	require.NoError(t, err)
+	require.NoError(t, plain.Set(ctx, "u1", User{Name: "old"}))
+	require.NoError(t, plain.Set(ctx, "u2", User{Name: "old"}))
+
+	// Encryption enabled on a live cache: old plaintext entries are reloaded, not errors.
+	codec, err := NewEncryptCodec(JsonCodec, EncryptConfig{Keys: map[byte][]byte{1: []byte("0123456789abcdef")}, ActiveKey: 1})
+	require.NoError(t, err)
+	c, err := NewRedis[User](RedisConfig{Client: rdb, Prefix: "test:undecodable", DefaultTTL: time.Minute, Codec: codec})
+	require.NoError(t, err)
+
+	_, err = c.Get(ctx, "u1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	got, err := c.GetOrLoad(ctx, "u1", func(ctx context.Context, key string) (User, error) {
+		return User{Name: "new"}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new", got.Name)
+	got, err = c.Get(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "new", got.Name)
+
+	many, err := c.GetOrLoadMany(ctx, []string{"u2"}, func(ctx context.Context, keys []string) (map[string]User, error) {
+		return map[string]User{"u2": {Name: "new"}}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new", many["u2"].Name)
+}
+
 // ==================== Two-Level Cache Tests ====================
 
 func TestTwoLevelCache_BasicOps(t *testing.T) {
@@ -426,3 +806,632 @@ func TestTwoLevelCache_GetOrLoad_LoaderError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "db connection failed")
 }
+
+func TestTwoLevelCache_Invalidation(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	newInstance := func() Cache[string] {
+		c, err := NewTwoLevel[string](TwoLevelConfig{
+			Local:        MemoryConfig{MaxSize: 100, DefaultTTL: time.Minute},
+			Remote:       RedisConfig{Client: rdb, Prefix: "test:tl-inv", DefaultTTL: time.Minute},
+			Invalidation: InvalidationConfig{Channel: "test:tl-inv:events"},
+		})
+		require.NoError(t, err)
+		return c
+	}
+	a, b := newInstance(), newInstance()
+	defer a.Close()
+	defer b.Close()
+	defer rdb.Del(ctx, "test:tl-inv:k1")
+
+	// Give both subscribers time to attach.
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, a.Set(ctx, "k1", "v1"))
+	v, err := b.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", v)
+
+	// b now holds k1 locally; a write on a must evict it from b.
+	require.NoError(t, a.Set(ctx, "k1", "v2"))
+	assert.Eventually(t, func() bool {
+		v, err := b.Get(ctx, "k1")
+		return err == nil && v == "v2"
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, a.Delete(ctx, "k1"))
+	assert.Eventually(t, func() bool {
+		_, err := b.Get(ctx, "k1")
+		return errors.Is(err, ErrNotFound)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidator_Handle(t *testing.T) {
+	var evicted []string
+	var flushed int
+	iv := &invalidator{
+		origin:  "self",
+		onKeys:  func(keys []string) { evicted = append(evicted, keys...) },
+		onFlush: func() { flushed++ },
+	}
+
+	iv.handle(`{"o":"self","k":["a"]}`)
+	assert.Empty(t, evicted, "own events must be ignored")
+
+	iv.handle(`{"o":"peer","k":["a","b"]}`)
+	assert.Equal(t, []string{"a", "b"}, evicted)
+
+	iv.handle("garbage")
+	assert.Equal(t, 1, flushed)
+
+	iv.handle(`{"o":"peer","f":true}`)
+	assert.Equal(t, 2, flushed)
+}
+
+func TestInvalidator_RecoversFromPanic(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	var calls, flushed atomic.Int32
+	iv := newInvalidator(rdb, InvalidationConfig{Channel: "test:iv-panic"}, func(keys []string) {
+		if calls.Add(1) == 1 {
+			panic("handler bug")
+		}
+	}, func() { flushed.Add(1) })
+	defer iv.close()
+	peer := newInvalidator(rdb, InvalidationConfig{Channel: "test:iv-panic"}, func([]string) {}, func() {})
+	defer peer.close()
+
+	// Publish until the first (panicking) message is received, then keep
+	// going: the invalidator must resume receiving after the panic.
+	assert.Eventually(t, func() bool {
+		require.NoError(t, peer.publish(ctx, "k"))
+		return calls.Load() >= 2
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.GreaterOrEqual(t, flushed.Load(), int32(1), "local layer must be flushed after a panic")
+}
+
+func TestTwoLevelCache_NegativeCaching(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	c, err := NewTwoLevel[string](TwoLevelConfig{
+		Local:  MemoryConfig{MaxSize: 100, DefaultTTL: 30 * time.Second, Negative: NegativeConfig{TTL: time.Second}},
+		Remote: RedisConfig{Client: rdb, Prefix: "test:tl-neg", DefaultTTL: time.Minute, Negative: NegativeConfig{TTL: time.Minute}},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+	defer rdb.Del(ctx, "test:tl-neg:k1")
+
+	var callCount atomic.Int32
+	loader := func(ctx context.Context, key string) (string, error) {
+		callCount.Add(1)
+		return "", ErrNotFound
+	}
+
+	_, err = c.GetOrLoad(ctx, "k1", loader)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Served from the local absent marker
+	_, err = c.GetOrLoad(ctx, "k1", loader)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Served from the remote absent marker once L1 is gone
+	require.NoError(t, c.(*twoLevelCache[string]).local.Delete(ctx, "k1"))
+	_, err = c.GetOrLoad(ctx, "k1", loader)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, int32(1), callCount.Load())
+}
+
+func TestTwoLevelCache_GetOrLoadMany(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	c, err := NewTwoLevel[string](TwoLevelConfig{
+		Local:  MemoryConfig{MaxSize: 100, DefaultTTL: 30 * time.Second},
+		Remote: RedisConfig{Client: rdb, Prefix: "test:tl-many", DefaultTTL: time.Minute},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+	defer rdb.Del(ctx, "test:tl-many:k1", "test:tl-many:k2", "test:tl-many:k3")
+
+	require.NoError(t, c.Set(ctx, "k1", "local"))
+	data, _ := JsonCodec.Marshal("remote")
+	rdb.Set(ctx, "test:tl-many:k2", data, time.Minute)
+
+	var requested []string
+	got, err := c.GetOrLoadMany(ctx, []string{"k1", "k2", "k3"}, func(ctx context.Context, keys []string) (map[string]string, error) {
+		requested = keys
+		return map[string]string{"k3": "origin"}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k1": "local", "k2": "remote", "k3": "origin"}, got)
+	assert.Equal(t, []string{"k3"}, requested)
+
+	// All three are in L1 now
+	rdb.Del(ctx, "test:tl-many:k1", "test:tl-many:k2", "test:tl-many:k3")
+	got, err = c.GetMany(ctx, []string{"k1", "k2", "k3"})
+	require.NoError(t, err)
+	assert.Len(t, got, 3)
+}
+
+func TestWithTags(t *testing.T) {
+	o := applyOptions([]Option{WithTTL(time.Second), WithTags("a"), WithTags("b", "c")})
+	assert.Equal(t, time.Second, o.TTL)
+	assert.Equal(t, []string{"a", "b", "c"}, o.Tags)
+}
+
+func TestRedisCache_InvalidateTag(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	c, err := NewRedis[string](RedisConfig{Client: rdb, Prefix: "test:tags", DefaultTTL: time.Minute})
+	require.NoError(t, err)
+	defer c.Close()
+	defer rdb.Del(ctx, "test:tags:k1", "test:tags:k2", "test:tags:k3", "test:tags:#tag:user:1")
+
+	require.NoError(t, c.Set(ctx, "k1", "v1", WithTags("user:1")))
+	require.NoError(t, c.SetMany(ctx, map[string]string{"k2": "v2"}, WithTags("user:1")))
+	require.NoError(t, c.Set(ctx, "k3", "v3"))
+
+	require.NoError(t, c.(TagInvalidator).InvalidateTag(ctx, "user:1"))
+
+	got, err := c.GetMany(ctx, []string{"k1", "k2", "k3"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k3": "v3"}, got)
+	assert.Equal(t, int64(0), rdb.Exists(ctx, "test:tags:#tag:user:1").Val())
+}
+
+func TestRedisCache_InvalidateTagConcurrentSet(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	c, err := NewRedis[string](RedisConfig{Client: rdb, Prefix: "test:tags-race", DefaultTTL: time.Minute})
+	require.NoError(t, err)
+	defer c.Close()
+	defer func() {
+		keys, _ := rdb.Keys(ctx, "test:tags-race:*").Result()
+		if len(keys) > 0 {
+			rdb.Del(ctx, keys...)
+		}
+	}()
+
+	// Tag keys while invalidations run: every key written before the final
+	// InvalidateTag must be deleted by it, none may fall out of the tag set.
+	const n = 2000
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for i := 0; i < n; i++ {
+			_ = c.Set(ctx, fmt.Sprintf("k%d", i), "v", WithTags("t"))
+		}
+	}()
+	for done := false; !done; {
+		select {
+		case <-written:
+			done = true
+		default:
+			require.NoError(t, c.(TagInvalidator).InvalidateTag(ctx, "t"))
+		}
+	}
+	require.NoError(t, c.(TagInvalidator).InvalidateTag(ctx, "t"))
+
+	left, err := rdb.Keys(ctx, "test:tags-race:k*").Result()
+	require.NoError(t, err)
+	assert.Empty(t, left, "tagged keys survived InvalidateTag")
+}
+
+func TestRedisCache_Flush(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	plain, err := NewRedis[string](RedisConfig{Client: rdb, Prefix: "test:flush"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, plain.(TagInvalidator).Flush(ctx), ErrNotVersioned)
+
+	c, err := NewRedis[string](RedisConfig{Client: rdb, Prefix: "test:flush", DefaultTTL: time.Minute, Versioned: true})
+	require.NoError(t, err)
+	defer c.Close()
+	defer func() {
+		keys, _ := rdb.Keys(ctx, "test:flush:*").Result()
+		if len(keys) > 0 {
+			rdb.Del(ctx, keys...)
+		}
+	}()
+
+	require.NoError(t, c.Set(ctx, "k1", "v1"))
+	v, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", v)
+
+	require.NoError(t, c.(TagInvalidator).Flush(ctx))
+	_, err = c.Get(ctx, "k1")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Another instance sees the new version once its cached copy is stale
+	other, err := NewRedis[string](RedisConfig{Client: rdb, Prefix: "test:flush", Versioned: true})
+	require.NoError(t, err)
+	_, err = other.Get(ctx, "k1")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestTwoLevelCache_InvalidateTag(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+
+	c, err := NewTwoLevel[string](TwoLevelConfig{
+		Local:  MemoryConfig{MaxSize: 100, DefaultTTL: 30 * time.Second},
+		Remote: RedisConfig{Client: rdb, Prefix: "test:tl-tags", DefaultTTL: time.Minute},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+	defer rdb.Del(ctx, "test:tl-tags:k1", "test:tl-tags:#tag:t")
+
+	require.NoError(t, c.Set(ctx, "k1", "v1", WithTags("t")))
+	require.NoError(t, c.(TagInvalidator).InvalidateTag(ctx, "t"))
+
+	_, err = c.Get(ctx, "k1")
+	assert.True(t, errors.Is(err, ErrNotFound), "local copy must be dropped too")
+}
+
+func TestGobCodec(t *testing.T) {
+	data, err := GobCodec.Marshal(User{Name: "alice", Age: 30})
+	require.NoError(t, err)
+	var got User
+	require.NoError(t, GobCodec.Unmarshal(data, &got))
+	assert.Equal(t, User{Name: "alice", Age: 30}, got)
+}
+
+func TestCompressCodec(t *testing.T) {
+	for _, alg := range []Compression{Gzip, Flate} {
+		codec, err := NewCompressCodec(JsonCodec, CompressConfig{Algorithm: alg, Threshold: 64})
+		require.NoError(t, err)
+
+		small := User{Name: "bob"}
+		data, err := codec.Marshal(small)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{compressMagic, compressNone}, data[:compressHeaderLen])
+		var got User
+		require.NoError(t, codec.Unmarshal(data, &got))
+		assert.Equal(t, small, got)
+
+		large := User{Name: strings.Repeat("x", 4096)}
+		data, err = codec.Marshal(large)
+		require.NoError(t, err)
+		assert.Less(t, len(data), 200)
+		got = User{}
+		require.NoError(t, codec.Unmarshal(data, &got))
+		assert.Equal(t, large, got)
+	}
+
+	// Entries written before compression was enabled still decode
+	codec, err := NewCompressCodec(JsonCodec, CompressConfig{})
+	require.NoError(t, err)
+	legacy, _ := JsonCodec.Marshal(User{Name: "old"})
+	var got User
+	require.NoError(t, codec.Unmarshal(legacy, &got))
+	assert.Equal(t, "old", got.Name)
+
+	// Legacy gob entries too: small scalars encode with a leading 0x03 (e.g. 03 04 00 0a for 5)
+	gobCompress, err := NewCompressCodec(GobCodec, CompressConfig{Algorithm: Flate})
+	require.NoError(t, err)
+	var (
+		n    int
+		b    bool
+		name string
+		u    User
+	)
+	for _, tc := range []struct{ in, out any }{{5, &n}, {true, &b}, {"s", &name}, {User{Name: "old"}, &u}} {
+		legacy, err := GobCodec.Marshal(tc.in)
+		require.NoError(t, err)
+		require.NoError(t, gobCompress.Unmarshal(legacy, tc.out))
+	}
+	assert.Equal(t, 5, n)
+	assert.True(t, b)
+	assert.Equal(t, "s", name)
+	assert.Equal(t, "old", u.Name)
+}
+
+func TestEncryptCodec(t *testing.T) {
+	key1 := []byte("0123456789abcdef")
+	key2 := []byte("fedcba9876543210fedcba9876543210")
+
+	old, err := NewEncryptCodec(JsonCodec, EncryptConfig{Keys: map[byte][]byte{1: key1}, ActiveKey: 1})
+	require.NoError(t, err)
+	data, err := old.Marshal(User{Name: "alice"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "alice")
+
+	// After rotation new values use key 2 and old ones still decrypt
+	rotated, err := NewEncryptCodec(JsonCodec, EncryptConfig{Keys: map[byte][]byte{1: key1, 2: key2}, ActiveKey: 2})
+	require.NoError(t, err)
+	var got User
+	require.NoError(t, rotated.Unmarshal(data, &got))
+	assert.Equal(t, "alice", got.Name)
+
+	data, err = rotated.Marshal(User{Name: "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, byte(2), data[1])
+	assert.Error(t, old.Unmarshal(data, &got), "retired instance does not know key 2")
+
+	data[len(data)-1] ^= 0xff
+	assert.Error(t, rotated.Unmarshal(data, &got), "tampered ciphertext must fail")
+
+	plain, _ := JsonCodec.Marshal(User{Name: "plain"})
+	assert.ErrorIs(t, rotated.Unmarshal(plain, &got), ErrNotEncrypted)
+
+	_, err = NewEncryptCodec(JsonCodec, EncryptConfig{Keys: map[byte][]byte{1: []byte("short")}, ActiveKey: 1})
+	assert.Error(t, err)
+	_, err = NewEncryptCodec(JsonCodec, EncryptConfig{Keys: map[byte][]byte{1: key1}, ActiveKey: 2})
+	assert.Error(t, err)
+}
+
+func TestEncryptCodec_Compressed(t *testing.T) {
+	compress, err := NewCompressCodec(GobCodec, CompressConfig{Threshold: 16})
+	require.NoError(t, err)
+	codec, err := NewEncryptCodec(compress, EncryptConfig{Keys: map[byte][]byte{7: []byte("0123456789abcdef")}, ActiveKey: 7})
+	require.NoError(t, err)
+
+	want := User{Name: strings.Repeat("y", 1024), Age: 3}
+	data, err := codec.Marshal(want)
+	require.NoError(t, err)
+	assert.Less(t, len(data), 200)
+	var got User
+	require.NoError(t, codec.Unmarshal(data, &got))
+	assert.Equal(t, want, got)
+}
+
+func TestRedisCache_LockedLoad(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+	defer rdb.Del(ctx, "test:locked:k1")
+
+	// Two caches sharing a lock provider stand in for two instances.
+	locks := dlock.NewMemoryProvider()
+	cfg := RedisConfig{
+		Client:     rdb,
+		Prefix:     "test:locked",
+		DefaultTTL: time.Minute,
+		Lock:       LockConfig{Provider: locks, PollInterval: 10 * time.Millisecond},
+	}
+	a, err := NewRedis[string](cfg)
+	require.NoError(t, err)
+	b, err := NewRedis[string](cfg)
+	require.NoError(t, err)
+
+	var callCount atomic.Int32
+	loader := func(ctx context.Context, key string) (string, error) {
+		callCount.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return "v1", nil
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range []Cache[string]{a, b, a, b} {
+		wg.Add(1)
+		go func(c Cache[string]) {
+			defer wg.Done()
+			v, err := c.GetOrLoad(ctx, "k1", loader)
+			assert.NoError(t, err)
+			assert.Equal(t, "v1", v)
+		}(c)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), callCount.Load())
+}
+
+func TestRedisCache_LockedLoad_WaitTimeout(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+	defer rdb.Del(ctx, "test:locked-timeout:k1")
+
+	locks := dlock.NewMemoryProvider()
+	c, err := NewRedis[string](RedisConfig{
+		Client:     rdb,
+		Prefix:     "test:locked-timeout",
+		DefaultTTL: time.Minute,
+		Lock:       LockConfig{Provider: locks, Wait: 50 * time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	// Another instance holds the lock and never stores a value.
+	held := locks.NewMutex("test:locked-timeout:k1")
+	require.NoError(t, held.Lock(ctx))
+	defer held.Unlock(ctx)
+
+	v, err := c.GetOrLoad(ctx, "k1", func(ctx context.Context, key string) (string, error) {
+		return "self", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "self", v)
+}
+
+func TestRedisCache_LockedLoad_NoLockKeysLeft(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	ctx := context.Background()
+	cleanup := func() {
+		keys, _ := rdb.Keys(ctx, "test:locked-keys*").Result()
+		if len(keys) > 0 {
+			rdb.Del(ctx, keys...)
+		}
+	}
+	cleanup()
+	defer cleanup()
+
+	locks := dlock.NewRedisProvider(goredisv9.NewPool(rdb), dlock.WithRedisKeyPrefix("test:locked-keys-lock:"))
+	c, err := NewRedis[string](RedisConfig{
+		Client:     rdb,
+		Prefix:     "test:locked-keys",
+		DefaultTTL: time.Minute,
+		Lock:       LockConfig{Provider: locks},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err := c.GetOrLoad(ctx, fmt.Sprintf("k%d", i), func(ctx context.Context, key string) (string, error) {
+			return "v", nil
+		})
+		require.NoError(t, err)
+	}
+
+	// Loading many distinct keys must not leave lock state behind.
+	left, err := rdb.Keys(ctx, "test:locked-keys-lock:*").Result()
+	require.NoError(t, err)
+	assert.Empty(t, left)
+}
+
+func TestBreaker_States(t *testing.T) {
+	ctx := context.Background()
+	var purged []string
+	b := newBreaker(BreakerConfig{FailureThreshold: 2, OpenTime: 50 * time.Millisecond}, "test", func(ctx context.Context, keys []string, overflow bool) error {
+		purged = keys
+		return nil
+	})
+	down := errors.New("connection refused")
+
+	assert.True(t, b.allow())
+	assert.True(t, b.report(ctx, down))
+	assert.True(t, b.allow(), "still closed below the threshold")
+	assert.False(t, b.report(ctx, ErrNotFound), "a miss is not a failure")
+	assert.True(t, b.report(ctx, down))
+	assert.True(t, b.report(ctx, down))
+	assert.False(t, b.allow(), "open after two consecutive failures")
+
+	b.markDirty("k1")
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, b.allow(), "one probe after OpenTime")
+	assert.False(t, b.allow(), "only one probe at a time")
+	b.report(ctx, down)
+	assert.False(t, b.allow(), "failed probe reopens")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, b.allow())
+	b.report(ctx, nil)
+	assert.True(t, b.allow(), "closed after a successful probe")
+	assert.Eventually(t, func() bool { return b.recovering.Load() == false && len(purged) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"k1"}, purged)
+}
+
+func TestTwoLevelCache_BreakerOpen(t *testing.T) {
+	ctx := context.Background()
+	// Nothing listens on port 1, so every Redis call fails fast.
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 50 * time.Millisecond})
+	defer rdb.Close()
+
+	c, err := NewTwoLevel[string](TwoLevelConfig{
+		Local:   MemoryConfig{MaxSize: 100, DefaultTTL: time.Minute},
+		Remote:  RedisConfig{Client: rdb, Prefix: "test:breaker", DefaultTTL: time.Minute},
+		Breaker: BreakerConfig{FailureThreshold: 1, OpenTime: time.Minute},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	var callCount atomic.Int32
+	loader := func(ctx context.Context, key string) (string, error) {
+		callCount.Add(1)
+		return "from-db", nil
+	}
+
+	// The first call hits the Redis error, opens the breaker and still loads.
+	v, err := c.GetOrLoad(ctx, "k1", loader)
+	require.NoError(t, err)
+	assert.Equal(t, "from-db", v)
+	assert.False(t, c.(*twoLevelCache[string]).breaker.allow())
+
+	v, err = c.GetOrLoad(ctx, "k1", loader)
+	require.NoError(t, err)
+	assert.Equal(t, "from-db", v)
+	assert.Equal(t, int32(1), callCount.Load(), "served from the local layer")
+
+	require.NoError(t, c.Set(ctx, "k2", "local-only"))
+	v, err = c.Get(ctx, "k2")
+	require.NoError(t, err)
+	assert.Equal(t, "local-only", v)
+
+	require.NoError(t, c.Delete(ctx, "k2"))
+	_, err = c.Get(ctx, "k2")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	got, err := c.GetOrLoadMany(ctx, []string{"k1", "k3"}, func(ctx context.Context, keys []string) (map[string]string, error) {
+		return map[string]string{"k3": "batch"}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k1": "from-db", "k3": "batch"}, got)
+}
+
+func TestMemoryCache_MaxWeight(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemory[string](MemoryConfig{
+		MaxWeight:  1000,
+		Weigher:    func(key string, value any) uint32 { return uint32(len(value.(string))) },
+		DefaultTTL: time.Minute,
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, c.Set(ctx, fmt.Sprintf("k%d", i), strings.Repeat("x", 400)))
+	}
+	assert.Eventually(t, func() bool {
+		got, _ := c.GetMany(ctx, []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8", "k9"})
+		return len(got) <= 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Greater(t, c.Stats().Evictions, uint64(0))
+}
+
+func TestDefaultWeigher(t *testing.T) {
+	assert.Equal(t, uint32(len("key")+len(`"value"`)), defaultWeigher("key", "value"))
+	assert.Equal(t, uint32(len("key")+1), defaultWeigher("key", nil))
+}
+
+func TestMemoryCache_TTLFunc(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemory[string](MemoryConfig{
+		MaxSize:    100,
+		DefaultTTL: time.Minute,
+		TTLFunc: func(key string, value any) time.Duration {
+			if strings.HasPrefix(key, "short:") {
+				return 50 * time.Millisecond
+			}
+			return 0
+		},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.Set(ctx, "short:1", "v"))
+	require.NoError(t, c.Set(ctx, "long:1", "v"))
+	_, err = c.GetOrLoad(ctx, "short:2", func(ctx context.Context, key string) (string, error) {
+		return "loaded", nil
+	})
+	require.NoError(t, err)
+	// WithTTL still wins over TTLFunc
+	require.NoError(t, c.Set(ctx, "short:3", "v", WithTTL(time.Minute)))
+
+	time.Sleep(100 * time.Millisecond)
+	got, err := c.GetMany(ctx, []string{"short:1", "short:2", "short:3", "long:1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"short:3": "v", "long:1": "v"}, got)
+}
+
+func TestMemoryCache_ExpireAfterAccess(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemory[string](MemoryConfig{
+		MaxSize:           100,
+		DefaultTTL:        time.Minute,
+		ExpireAfterAccess: 150 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.Set(ctx, "hot", "v"))
+	require.NoError(t, c.Set(ctx, "cold", "v"))
+	for i := 0; i < 4; i++ {
+		time.Sleep(60 * time.Millisecond)
+		_, err := c.Get(ctx, "hot")
+		require.NoError(t, err, "reads keep the entry alive")
+	}
+	_, err = c.Get(ctx, "cold")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
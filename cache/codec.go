@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/json"
 
 	"github.com/Cotary/go-lib/common/utils"
@@ -15,8 +17,15 @@ type Codec interface {
 var (
 	JsonCodec    Codec = jsonIterCodec{}
 	StdJsonCodec Codec = stdJsonCodec{}
+	// GobCodec is a compact binary codec for Go-only consumers. Types stored
+	// behind interfaces must be registered with gob.Register.
+	GobCodec Codec = gobCodec{}
 )
 
+// DefaultAbsentMarker is stored in Redis for keys cached as not existing.
+// The leading NUL byte keeps it distinct from any JSON encoding.
+var DefaultAbsentMarker = []byte("\x00cache:absent")
+
 // jsonIterCodec uses the project's json-iterator configuration (utils.NJson).
 type jsonIterCodec struct{}
 
@@ -38,3 +47,18 @@ func (stdJsonCodec) Marshal(v any) ([]byte, error) {
 func (stdJsonCodec) Unmarshal(data []byte, v any) error {
 	return json.Unmarshal(data, v)
 }
+
+// gobCodec uses encoding/gob.
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Compression selects the algorithm used by NewCompressCodec.
+type Compression int
+
+const (
+	Gzip Compression = iota
+	Flate
+)
+
+// Every value encoded by a compress codec starts with compressMagic followed
+// by one of the algorithm bytes. 0xC1 can't start a JSON document (it is not
+// valid UTF-8), a gob stream (it is not a valid gob length prefix) or a
+// msgpack value (the byte is reserved), so entries written before the codec
+// was introduced are still passed to the inner codec untouched.
+const (
+	compressMagic     byte = 0xC1
+	compressHeaderLen      = 2
+
+	compressNone  byte = 0x01
+	compressGzip  byte = 0x02
+	compressFlate byte = 0x03
+)
+
+const defaultCompressThreshold = 1024
+
+type CompressConfig struct {
+	Algorithm Compression
+	// Threshold is the encoded size in bytes from which values are
+	// compressed (default 1KB); smaller values are stored as is.
+	Threshold int
+	// Level is passed to gzip / flate; 0 uses flate.DefaultCompression.
+	Level int
+}
+
+type compressCodec struct {
+	inner     Codec
+	algorithm Compression
+	threshold int
+	level     int
+}
+
+// NewCompressCodec wraps inner so large values are compressed before they
+// are stored. Values written by inner alone still decode.
+func NewCompressCodec(inner Codec, cfg CompressConfig) (Codec, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("cache: compress codec requires an inner codec")
+	}
+	if cfg.Algorithm != Gzip && cfg.Algorithm != Flate {
+		return nil, fmt.Errorf("cache: unknown compression algorithm %d", cfg.Algorithm)
+	}
+	threshold := cfg.Threshold
+	if threshold <= 0 {
+		threshold = defaultCompressThreshold
+	}
+	level := cfg.Level
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return nil, fmt.Errorf("cache: invalid compression level %d", level)
+	}
+	return &compressCodec{inner: inner, algorithm: cfg.Algorithm, threshold: threshold, level: level}, nil
+}
+
+func (c *compressCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.threshold {
+		return append([]byte{compressMagic, compressNone}, data...), nil
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if c.algorithm == Flate {
+		buf.Write([]byte{compressMagic, compressFlate})
+		w, err = flate.NewWriter(&buf, c.level)
+	} else {
+		buf.Write([]byte{compressMagic, compressGzip})
+		w, err = gzip.NewWriterLevel(&buf, c.level)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: compress: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("cache: compress: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("cache: compress: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *compressCodec) Unmarshal(data []byte, v any) error {
+	if len(data) < compressHeaderLen || data[0] != compressMagic {
+		// Written before compression was enabled.
+		return c.inner.Unmarshal(data, v)
+	}
+
+	body := data[compressHeaderLen:]
+	var r io.ReadCloser
+	switch data[1] {
+	case compressNone:
+		return c.inner.Unmarshal(body, v)
+	case compressGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("cache: decompress: %w", err)
+		}
+		r = gr
+	case compressFlate:
+		r = flate.NewReader(bytes.NewReader(body))
+	default:
+		return fmt.Errorf("cache: unknown compression header %d", data[1])
+	}
+	defer r.Close()
+
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("cache: decompress: %w", err)
+	}
+	return c.inner.Unmarshal(plain, v)
+}
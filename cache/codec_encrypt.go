@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/Cotary/go-lib/common/utils"
+)
+
+// encryptMagic starts every value written by an encrypt codec. It is
+// followed by the key ID, the 12-byte GCM nonce and the ciphertext.
+const (
+	encryptMagic     byte = 0xE1
+	encryptNonceSize      = 12
+	encryptHeaderLen      = 2 + encryptNonceSize
+)
+
+// ErrNotEncrypted is returned when reading a value that was not written by
+// an encrypt codec, e.g. plaintext left over from before encryption was enabled.
+var ErrNotEncrypted = errors.New("cache: value is not encrypted")
+
+type EncryptConfig struct {
+	// Keys maps key IDs to AES keys (16, 24 or 32 bytes). Keep retired keys
+	// here until every entry they encrypted has expired.
+	Keys map[byte][]byte
+	// ActiveKey is the ID of the key used for new values.
+	ActiveKey byte
+}
+
+type encryptCodec struct {
+	inner  Codec
+	keys   map[byte][]byte
+	active byte
+}
+
+// NewEncryptCodec wraps inner with AES-GCM encryption. To also compress,
+// pass a compress codec as inner; ciphertext does not compress.
+func NewEncryptCodec(inner Codec, cfg EncryptConfig) (Codec, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("cache: encrypt codec requires an inner codec")
+	}
+	if _, ok := cfg.Keys[cfg.ActiveKey]; !ok {
+		return nil, fmt.Errorf("cache: active key %d not found in Keys", cfg.ActiveKey)
+	}
+
+	keys := make(map[byte][]byte, len(cfg.Keys))
+	for id, key := range cfg.Keys {
+		if _, err := utils.NewAESHelperGCM(key); err != nil {
+			return nil, fmt.Errorf("cache: key %d: %w", id, err)
+		}
+		keys[id] = append([]byte(nil), key...)
+	}
+	return &encryptCodec{inner: inner, keys: keys, active: cfg.ActiveKey}, nil
+}
+
+func (c *encryptCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherHex, nonce, err := utils.EncryptGCM(c.keys[c.active], string(data))
+	if err != nil {
+		return nil, fmt.Errorf("cache: encrypt: %w", err)
+	}
+	cipherText, err := hex.DecodeString(cipherHex)
+	if err != nil {
+		return nil, fmt.Errorf("cache: encrypt: %w", err)
+	}
+
+	out := make([]byte, 0, encryptHeaderLen+len(cipherText))
+	out = append(out, encryptMagic, c.active)
+	out = append(out, nonce...)
+	return append(out, cipherText...), nil
+}
+
+func (c *encryptCodec) Unmarshal(data []byte, v any) error {
+	if len(data) < encryptHeaderLen || data[0] != encryptMagic {
+		return ErrNotEncrypted
+	}
+	key, ok := c.keys[data[1]]
+	if !ok {
+		return fmt.Errorf("cache: unknown encryption key %d", data[1])
+	}
+
+	nonce := data[2:encryptHeaderLen]
+	plain, err := utils.DecryptGCM(key, nonce, hex.EncodeToString(data[encryptHeaderLen:]))
+	if err != nil {
+		return fmt.Errorf("cache: decrypt: %w", err)
+	}
+	return c.inner.Unmarshal([]byte(plain), v)
+}
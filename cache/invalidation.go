@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationConfig enables cross-instance eviction of the local layer of a
+// two-level cache. Every instance subscribed to the same Channel evicts keys
+// written or deleted by any other instance.
+type InvalidationConfig struct {
+	// Channel is the Redis pub/sub channel. Empty disables invalidation.
+	Channel string
+	// RetryDelay is the pause before receiving again after a connection error (default 1s).
+	RetryDelay time.Duration
+	// PingInterval is how long the subscriber may stay idle before it pings
+	// Redis to detect a dead connection (default 30s).
+	PingInterval time.Duration
+}
+
+const (
+	defaultInvalidationRetryDelay   = time.Second
+	defaultInvalidationPingInterval = 30 * time.Second
+)
+
+// invalidationMessage is the payload broadcast on the invalidation channel.
+type invalidationMessage struct {
+	Origin string   `json:"o"`
+	Keys   []string `json:"k"`
+	Flush  bool     `json:"f,omitempty"` // drop the whole local layer
+}
+
+// invalidator publishes key invalidations and applies the ones received from
+// other instances. When the subscription drops, events may have been missed,
+// so the whole local layer is flushed on disconnect and again on resubscribe.
+type invalidator struct {
+	client       redis.UniversalClient
+	channel      string
+	origin       string
+	retryDelay   time.Duration
+	pingInterval time.Duration
+	onKeys       func(keys []string)
+	onFlush      func()
+
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newInvalidator(client redis.UniversalClient, cfg InvalidationConfig, onKeys func([]string), onFlush func()) *invalidator {
+	retryDelay := cfg.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = defaultInvalidationRetryDelay
+	}
+	pingInterval := cfg.PingInterval
+	if pingInterval <= 0 {
+		pingInterval = defaultInvalidationPingInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	iv := &invalidator{
+		client:       client,
+		channel:      cfg.Channel,
+		origin:       uuid.NewString(),
+		retryDelay:   retryDelay,
+		pingInterval: pingInterval,
+		onKeys:       onKeys,
+		onFlush:      onFlush,
+		pubsub:       client.Subscribe(ctx, cfg.Channel),
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+	coroutines.SafeGo(ctx, iv.run)
+	return iv
+}
+
+// publish broadcasts that keys changed so other instances drop their local copies.
+func (iv *invalidator) publish(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return iv.send(ctx, invalidationMessage{Origin: iv.origin, Keys: keys})
+}
+
+// publishFlush tells other instances to drop their whole local layer.
+func (iv *invalidator) publishFlush(ctx context.Context) error {
+	return iv.send(ctx, invalidationMessage{Origin: iv.origin, Flush: true})
+}
+
+func (iv *invalidator) send(ctx context.Context, msg invalidationMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("cache: marshal invalidation: %w", err)
+	}
+	if err := iv.client.Publish(ctx, iv.channel, data).Err(); err != nil {
+		return fmt.Errorf("cache: publish invalidation: %w", err)
+	}
+	return nil
+}
+
+// run keeps receiving until the invalidator is closed. A panic in a callback
+// is reported and receiving restarts; the message being handled is lost, so
+// the local layer is flushed first.
+func (iv *invalidator) run(ctx context.Context) {
+	defer close(iv.done)
+	for {
+		coroutines.SafeFunc(ctx, iv.receive)
+		if ctx.Err() != nil {
+			return
+		}
+		iv.onFlush()
+	}
+}
+
+func (iv *invalidator) receive(ctx context.Context) {
+	lost := false
+	for {
+		msg, err := iv.pubsub.ReceiveTimeout(ctx, iv.pingInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// Idle connection: a failed ping makes go-redis reconnect.
+				if iv.pubsub.Ping(ctx) == nil {
+					continue
+				}
+			}
+			if !lost {
+				lost = true
+				iv.onFlush()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(iv.retryDelay):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" && lost {
+				lost = false
+				iv.onFlush()
+			}
+		case *redis.Message:
+			iv.handle(m.Payload)
+		}
+	}
+}
+
+func (iv *invalidator) handle(payload string) {
+	var msg invalidationMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		// Unknown payload: we cannot tell which keys changed, so drop everything.
+		iv.onFlush()
+		return
+	}
+	if msg.Origin == iv.origin {
+		return
+	}
+	if msg.Flush {
+		iv.onFlush()
+		return
+	}
+	iv.onKeys(msg.Keys)
+}
+
+func (iv *invalidator) close() error {
+	iv.cancel()
+	err := iv.pubsub.Close()
+	<-iv.done
+	return err
+}
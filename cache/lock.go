@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Cotary/go-lib/dlock"
+)
+
+const (
+	defaultLockWait         = 3 * time.Second
+	defaultLockPollInterval = 50 * time.Millisecond
+)
+
+// LockConfig makes GetOrLoad take a distributed lock per key, so a cold key
+// is loaded by one instance while the others wait for its result in Redis.
+// The lock's lifetime comes from the Provider (e.g. dlock.WithRedisExpiry)
+// and should exceed the loader's worst-case duration.
+//
+// A lock is created for every missed key, so the Provider must not keep
+// per-key state after a lock is released. The memory, Redis and etcd
+// providers clean up after themselves (with dlock.WithRedisFencing the
+// Redis provider adds one shared counter, not one per key). The database
+// provider keeps a row for every key it has ever locked and is not suitable.
+type LockConfig struct {
+	// Provider creates the per-key locks. Nil disables locking.
+	Provider dlock.Provider
+	// Wait is how long an instance that lost the lock re-reads Redis before
+	// it gives up and calls the loader itself (default 3s).
+	Wait time.Duration
+	// PollInterval is the pause between those reads (default 50ms).
+	PollInterval time.Duration
+}
+
+func (c LockConfig) wait() time.Duration {
+	if c.Wait <= 0 {
+		return defaultLockWait
+	}
+	return c.Wait
+}
+
+func (c LockConfig) pollInterval() time.Duration {
+	if c.PollInterval <= 0 {
+		return defaultLockPollInterval
+	}
+	return c.PollInterval
+}
+
+// lockedLoad runs load while holding the distributed lock for key. If
+// another instance holds it, the value it stores is returned instead; load
+// still runs if that takes longer than Wait or the lock backend fails.
+func (r *redisCache[T]) lockedLoad(ctx context.Context, key string, load func() (interface{}, error)) (interface{}, error) {
+	if r.lock.Provider == nil {
+		return load()
+	}
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mu := r.lock.Provider.NewMutex(ks + key)
+	err = mu.TryLock(ctx)
+	if err == nil {
+		defer mu.Unlock(context.WithoutCancel(ctx))
+		// The previous holder may have stored the value after our miss.
+		if v, ok, err := r.reread(ctx, key); ok {
+			return v, err
+		}
+		return load()
+	}
+	if !errors.Is(err, dlock.ErrLockFailed) {
+		return load()
+	}
+
+	deadline := time.NewTimer(r.lock.wait())
+	defer deadline.Stop()
+	ticker := time.NewTicker(r.lock.pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return load()
+		case <-ticker.C:
+			if v, ok, err := r.reread(ctx, key); ok {
+				return v, err
+			}
+		}
+	}
+}
+
+// reread reports whether key now holds a fresh value or the absent marker.
+func (r *redisCache[T]) reread(ctx context.Context, key string) (interface{}, bool, error) {
+	v, remaining, err := r.lookupTTL(ctx, key)
+	switch {
+	case err == nil && remaining > 0:
+		return v, true, nil
+	case errors.Is(err, errAbsent):
+		return nil, true, errAbsent
+	default:
+		return nil, false, nil
+	}
+}
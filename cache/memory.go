@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,25 +10,142 @@ import (
 )
 
 type MemoryConfig struct {
-	MaxSize    int
+	// MaxSize bounds the number of entries. Exactly one of MaxSize and
+	// MaxWeight must be set.
+	MaxSize int
+	// MaxWeight bounds the total weight of the entries as reported by Weigher.
+	MaxWeight uint64
+	// Weigher returns the weight of an entry; value is nil for keys cached as
+	// absent. The default is the JSON-encoded size of the value plus the key
+	// length, i.e. roughly the bytes held.
+	Weigher    func(key string, value any) uint32
 	DefaultTTL time.Duration
+	// TTLFunc picks the TTL of values stored without WithTTL, including
+	// loaded ones; returning 0 falls back to DefaultTTL.
+	TTLFunc func(key string, value any) time.Duration
+	// ExpireAfterAccess removes entries that have not been read for this
+	// long, even before their TTL. Zero disables it.
+	ExpireAfterAccess time.Duration
+	Negative          NegativeConfig
+	Refresh           RefreshConfig
+	Hook              Hook
+}
+
+// defaultWeigher weighs an entry by its encoded size.
+func defaultWeigher(key string, value any) uint32 {
+	if value == nil {
+		return uint32(len(key)) + 1
+	}
+	data, err := JsonCodec.Marshal(value)
+	if err != nil {
+		return uint32(len(key)) + 1
+	}
+	return uint32(len(key) + len(data))
+}
+
+// entry is what the memory cache stores; absent marks a cached "not found".
+// expireAt is the logical expiry (zero means never); with Refresh.StaleTTL
+// the entry physically outlives it and is considered stale in between.
+type entry[T any] struct {
+	value    T
+	absent   bool
+	expireAt time.Time
+}
+
+func (e entry[T]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// result converts the entry to what Get / GetOrLoad return.
+func (e entry[T]) result() (T, error) {
+	if e.absent {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return e.value, nil
+}
+
+// memoryExpiry removes an entry once it is past its logical expiry plus
+// Refresh.StaleTTL, or earlier if it goes unread for ExpireAfterAccess.
+type memoryExpiry[T any] struct {
+	stale time.Duration
+	idle  time.Duration
+}
+
+func (x memoryExpiry[T]) lifetime(e entry[T]) time.Duration {
+	d := noExpiry
+	if !e.expireAt.IsZero() {
+		d = time.Until(e.expireAt)
+		if !e.absent {
+			d += x.stale
+		}
+	}
+	if x.idle > 0 && x.idle < d {
+		d = x.idle
+	}
+	return d
+}
+
+func (x memoryExpiry[T]) ExpireAfterCreate(e otter.Entry[string, entry[T]]) time.Duration {
+	return x.lifetime(e.Value)
+}
+
+func (x memoryExpiry[T]) ExpireAfterUpdate(e otter.Entry[string, entry[T]], _ entry[T]) time.Duration {
+	return x.lifetime(e.Value)
+}
+
+func (x memoryExpiry[T]) ExpireAfterRead(e otter.Entry[string, entry[T]]) time.Duration {
+	if x.idle <= 0 {
+		return e.ExpiresAfter()
+	}
+	return x.lifetime(e.Value)
 }
 
 type memoryCache[T any] struct {
-	inner      *otter.Cache[string, T]
+	inner      *otter.Cache[string, entry[T]]
 	defaultTTL time.Duration
+	ttlFunc    func(key string, value any) time.Duration
+	negative   NegativeConfig
+	refresh    RefreshConfig
+	stats      *recorder
 }
 
 func NewMemory[T any](cfg MemoryConfig) (Cache[T], error) {
-	if cfg.MaxSize <= 0 {
-		return nil, fmt.Errorf("cache: MaxSize must be positive")
+	return newMemoryCache[T](cfg, newRecorder(cfg.Hook))
+}
+
+func newMemoryCache[T any](cfg MemoryConfig, stats *recorder) (*memoryCache[T], error) {
+	if (cfg.MaxSize > 0) == (cfg.MaxWeight > 0) {
+		return nil, fmt.Errorf("cache: exactly one of MaxSize and MaxWeight must be positive")
+	}
+	if err := cfg.Refresh.validate(); err != nil {
+		return nil, err
 	}
 
-	opts := &otter.Options[string, T]{
-		MaximumSize: cfg.MaxSize,
+	opts := &otter.Options[string, entry[T]]{
+		OnDeletion: func(e otter.DeletionEvent[string, entry[T]]) {
+			if e.WasEvicted() {
+				stats.evict(e.Key)
+			}
+		},
+		ExpiryCalculator: memoryExpiry[T]{stale: cfg.Refresh.StaleTTL, idle: cfg.ExpireAfterAccess},
+		// Never fires on its own; it only enables Refresh for refresh-ahead and stale reloads.
+		RefreshCalculator: otter.RefreshWriting[string, entry[T]](noExpiry),
 	}
-	if cfg.DefaultTTL > 0 {
-		opts.ExpiryCalculator = otter.ExpiryWriting[string, T](cfg.DefaultTTL)
+	if cfg.MaxSize > 0 {
+		opts.MaximumSize = cfg.MaxSize
+	} else {
+		weigher := cfg.Weigher
+		if weigher == nil {
+			weigher = defaultWeigher
+		}
+		opts.MaximumWeight = cfg.MaxWeight
+		opts.Weigher = func(key string, e entry[T]) uint32 {
+			if e.absent {
+				return weigher(key, nil)
+			}
+			return weigher(key, e.value)
+		}
 	}
 
 	c, err := otter.New(opts)
@@ -38,37 +156,202 @@ func NewMemory[T any](cfg MemoryConfig) (Cache[T], error) {
 	return &memoryCache[T]{
 		inner:      c,
 		defaultTTL: cfg.DefaultTTL,
+		ttlFunc:    cfg.TTLFunc,
+		negative:   cfg.Negative,
+		refresh:    cfg.Refresh,
+		stats:      stats,
 	}, nil
 }
 
-func (m *memoryCache[T]) Get(_ context.Context, key string) (T, error) {
-	v, ok := m.inner.GetIfPresent(key)
-	if !ok {
+// newEntry wraps value with its jittered logical expiry.
+func (m *memoryCache[T]) newEntry(value T, ttl time.Duration) entry[T] {
+	e := entry[T]{value: value}
+	if ttl = m.refresh.jitter(ttl); ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	}
+	return e
+}
+
+// ttl returns the TTL of a value stored without WithTTL.
+func (m *memoryCache[T]) ttl(key string, value T) time.Duration {
+	if m.ttlFunc != nil {
+		if ttl := m.ttlFunc(key, value); ttl > 0 {
+			return ttl
+		}
+	}
+	return m.defaultTTL
+}
+
+func (m *memoryCache[T]) absentEntry() entry[T] {
+	return entry[T]{absent: true, expireAt: time.Now().Add(m.negative.TTL)}
+}
+
+// loaderFor adapts loader to otter, recording each call and turning "not
+// found" errors into absent entries when negative caching is enabled.
+func (m *memoryCache[T]) loaderFor(loader LoaderFunc[T]) otter.Loader[string, entry[T]] {
+	loader = timedLoader(m.stats, loader, m.negative.notFound)
+	return otter.LoaderFunc[string, entry[T]](func(ctx context.Context, key string) (entry[T], error) {
+		v, err := loader(ctx, key)
+		if err != nil {
+			if m.negative.enabled() && m.negative.notFound(err) {
+				return m.absentEntry(), nil
+			}
+			return entry[T]{}, err
+		}
+		return m.newEntry(v, m.ttl(key, v)), nil
+	})
+}
+
+// lookup returns the cached value, ErrNotFound on a miss or a stale entry,
+// or errAbsent when the key is cached as not existing.
+func (m *memoryCache[T]) lookup(key string) (T, error) {
+	e, ok := m.inner.GetIfPresent(key)
+	if !ok || e.expired(time.Now()) {
 		var zero T
 		return zero, ErrNotFound
 	}
-	return v, nil
+	if e.absent {
+		return e.value, errAbsent
+	}
+	return e.value, nil
 }
 
-func (m *memoryCache[T]) Set(_ context.Context, key string, value T, opts ...Option) error {
-	m.inner.Set(key, value)
+// record counts the outcome of a lookup as a hit or a miss.
+func (m *memoryCache[T]) record(key string, err error) {
+	if err == nil || errors.Is(err, errAbsent) {
+		m.stats.hit(key, LayerLocal)
+	} else {
+		m.stats.miss(key)
+	}
+}
 
-	o := applyOptions(opts)
-	if o.TTL > 0 {
-		m.inner.SetExpiresAfter(key, o.TTL)
+func (m *memoryCache[T]) Get(_ context.Context, key string) (T, error) {
+	v, err := m.lookup(key)
+	m.record(key, err)
+	if errors.Is(err, errAbsent) {
+		return v, ErrNotFound
 	}
+	return v, err
+}
+
+func (m *memoryCache[T]) Set(_ context.Context, key string, value T, opts ...Option) error {
+	ttl := applyOptions(opts).TTL
+	if ttl <= 0 {
+		ttl = m.ttl(key, value)
+	}
+	m.inner.Set(key, m.newEntry(value, ttl))
 	return nil
 }
 
+// setAbsent caches key as not existing, if negative caching is enabled.
+func (m *memoryCache[T]) setAbsent(key string) {
+	if m.negative.enabled() {
+		m.inner.Set(key, m.absentEntry())
+	}
+}
+
 func (m *memoryCache[T]) Delete(_ context.Context, key string) error {
 	m.inner.Invalidate(key)
 	return nil
 }
 
+// clear drops every entry from the cache.
+func (m *memoryCache[T]) clear() {
+	m.inner.InvalidateAll()
+}
+
 func (m *memoryCache[T]) GetOrLoad(ctx context.Context, key string, loader LoaderFunc[T]) (T, error) {
-	return m.inner.Get(ctx, key, otter.LoaderFunc[string, T](func(ctx context.Context, key string) (T, error) {
-		return loader(ctx, key)
-	}))
+	if e, ok := m.inner.GetIfPresent(key); ok {
+		now := time.Now()
+		if !e.expired(now) {
+			m.stats.hit(key, LayerLocal)
+			if !e.absent && !e.expireAt.IsZero() && m.refresh.due(e.expireAt.Sub(now)) {
+				// Reload in the background; otter skips it if a load is already running.
+				m.inner.Refresh(context.WithoutCancel(ctx), key, m.loaderFor(loader))
+			}
+			return e.result()
+		}
+
+		// Stale: reload now and fall back to the stale value if that fails.
+		m.stats.miss(key)
+		res := <-m.inner.Refresh(ctx, key, m.loaderFor(loader))
+		if res.Err != nil {
+			return e.result()
+		}
+		return res.Value.result()
+	}
+
+	m.stats.miss(key)
+	e, err := m.inner.Get(ctx, key, m.loaderFor(loader))
+	if err != nil {
+		return e.value, err
+	}
+	return e.result()
+}
+
+func (m *memoryCache[T]) GetMany(_ context.Context, keys []string) (map[string]T, error) {
+	result := make(map[string]T, len(keys))
+	for _, key := range keys {
+		v, err := m.lookup(key)
+		m.record(key, err)
+		if err == nil {
+			result[key] = v
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryCache[T]) SetMany(ctx context.Context, items map[string]T, opts ...Option) error {
+	for key, value := range items {
+		_ = m.Set(ctx, key, value, opts...)
+	}
+	return nil
+}
+
+func (m *memoryCache[T]) DeleteMany(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		m.inner.Invalidate(key)
+	}
+	return nil
+}
+
+// GetOrLoadMany calls loader once for the keys that are missing or stale.
+// Unlike GetOrLoad, concurrent batches are not coalesced.
+func (m *memoryCache[T]) GetOrLoadMany(ctx context.Context, keys []string, loader BatchLoaderFunc[T]) (map[string]T, error) {
+	found := make(map[string]T, len(keys))
+	var absent []string
+	for _, key := range keys {
+		v, err := m.lookup(key)
+		m.record(key, err)
+		if err == nil {
+			found[key] = v
+		} else if errors.Is(err, errAbsent) {
+			absent = append(absent, key)
+		}
+	}
+
+	missing := missingKeys(keys, found, absent)
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	loaded, err := timedBatchLoader(m.stats, loader)(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range missing {
+		if v, ok := loaded[key]; ok {
+			m.inner.Set(key, m.newEntry(v, m.ttl(key, v)))
+			found[key] = v
+		} else {
+			m.setAbsent(key)
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryCache[T]) Stats() Stats {
+	return m.stats.snapshot()
 }
 
 func (m *memoryCache[T]) Close() error {
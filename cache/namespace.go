@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultVersionTTL = time.Second
+
+// namespace tracks the version embedded in every key of a versioned Redis
+// cache. Bumping the version in Redis orphans all existing keys at once; they
+// are never read again and expire with their own TTL.
+type namespace struct {
+	client redis.UniversalClient
+	key    string        // Redis key holding the version
+	ttl    time.Duration // how long a version read from Redis is reused
+
+	mu       sync.Mutex
+	version  string
+	expireAt time.Time
+	sf       singleflight.Group
+}
+
+func newNamespace(client redis.UniversalClient, key string, ttl time.Duration) *namespace {
+	if ttl <= 0 {
+		ttl = defaultVersionTTL
+	}
+	return &namespace{client: client, key: key, ttl: ttl}
+}
+
+func (n *namespace) store(version string) {
+	n.mu.Lock()
+	n.version = version
+	n.expireAt = time.Now().Add(n.ttl)
+	n.mu.Unlock()
+}
+
+// current returns the version, reading it from Redis when the cached copy is old.
+func (n *namespace) current(ctx context.Context) (string, error) {
+	n.mu.Lock()
+	version, fresh := n.version, time.Now().Before(n.expireAt)
+	n.mu.Unlock()
+	if version != "" && fresh {
+		return version, nil
+	}
+
+	v, err, _ := n.sf.Do("", func() (interface{}, error) {
+		version, err := n.client.Get(ctx, n.key).Result()
+		if err == redis.Nil {
+			version = "0"
+		} else if err != nil {
+			return nil, fmt.Errorf("cache: redis get namespace version: %w", err)
+		}
+		n.store(version)
+		return version, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+// bump moves the namespace to a new version.
+func (n *namespace) bump(ctx context.Context) error {
+	v, err := n.client.Incr(ctx, n.key).Result()
+	if err != nil {
+		return fmt.Errorf("cache: redis bump namespace version: %w", err)
+	}
+	n.store(strconv.FormatInt(v, 10))
+	return nil
+}
+
+// expire forces the next current call to re-read the version from Redis.
+func (n *namespace) expire() {
+	n.mu.Lock()
+	n.expireAt = time.Time{}
+	n.mu.Unlock()
+}
@@ -1,10 +1,14 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/Cotary/go-lib/common/coroutines"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/sync/singleflight"
 )
@@ -14,6 +18,16 @@ type RedisConfig struct {
 	Prefix     string
 	DefaultTTL time.Duration
 	Codec      Codec
+	Negative   NegativeConfig
+	Refresh    RefreshConfig
+	Lock       LockConfig
+	Hook       Hook
+	// Versioned embeds a namespace version in every key (Prefix:v<n>:key) so
+	// Flush can drop the whole Prefix in O(1) without SCAN.
+	Versioned bool
+	// VersionTTL is how long the version read from Redis is reused before it
+	// is read again (default 1s); other instances see a Flush within it.
+	VersionTTL time.Duration
 }
 
 type redisCache[T any] struct {
@@ -21,35 +35,72 @@ type redisCache[T any] struct {
 	prefix     string
 	defaultTTL time.Duration
 	codec      Codec
+	negative   NegativeConfig
+	marker     []byte
+	refresh    RefreshConfig
+	lock       LockConfig
+	ns         *namespace // nil unless Versioned
+	stats      *recorder
 	sf         singleflight.Group
+	refreshing sync.Map // keys with a refresh-ahead in flight
 }
 
 func NewRedis[T any](cfg RedisConfig) (Cache[T], error) {
+	return newRedisCache[T](cfg, newRecorder(cfg.Hook))
+}
+
+func newRedisCache[T any](cfg RedisConfig, stats *recorder) (*redisCache[T], error) {
 	if cfg.Client == nil {
 		return nil, fmt.Errorf("cache: Redis Client must not be nil")
 	}
 	if cfg.DefaultTTL <= 0 {
 		return nil, fmt.Errorf("cache: Redis DefaultTTL must be positive")
 	}
+	if err := cfg.Refresh.validate(); err != nil {
+		return nil, err
+	}
 
 	codec := cfg.Codec
 	if codec == nil {
 		codec = JsonCodec
 	}
 
-	return &redisCache[T]{
+	r := &redisCache[T]{
 		client:     cfg.Client,
 		prefix:     cfg.Prefix,
 		defaultTTL: cfg.DefaultTTL,
 		codec:      codec,
-	}, nil
+		negative:   cfg.Negative,
+		marker:     cfg.Negative.marker(),
+		refresh:    cfg.Refresh,
+		lock:       cfg.Lock,
+		stats:      stats,
+	}
+	if cfg.Versioned {
+		versionKey := versionKeyName
+		if cfg.Prefix != "" {
+			versionKey = cfg.Prefix + ":" + versionKeyName
+		}
+		r.ns = newNamespace(cfg.Client, versionKey, cfg.VersionTTL)
+	}
+	return r, nil
 }
 
-func (r *redisCache[T]) key(k string) string {
-	if r.prefix == "" {
-		return k
+// keyspace returns what every Redis key of this cache starts with: the
+// prefix and, when versioned, the current namespace version.
+func (r *redisCache[T]) keyspace(ctx context.Context) (string, error) {
+	ks := ""
+	if r.prefix != "" {
+		ks = r.prefix + ":"
 	}
-	return r.prefix + ":" + k
+	if r.ns != nil {
+		version, err := r.ns.current(ctx)
+		if err != nil {
+			return "", err
+		}
+		ks += "v" + version + ":"
+	}
+	return ks, nil
 }
 
 func (r *redisCache[T]) ttl(opts []Option) time.Duration {
@@ -60,21 +111,90 @@ func (r *redisCache[T]) ttl(opts []Option) time.Duration {
 	return r.defaultTTL
 }
 
-func (r *redisCache[T]) Get(ctx context.Context, key string) (T, error) {
+// expiry is the Redis TTL for a value with logical ttl: jittered, plus the
+// stale window during which GetOrLoad may still serve it.
+func (r *redisCache[T]) expiry(ttl time.Duration) time.Duration {
+	return r.refresh.jitter(ttl) + r.refresh.StaleTTL
+}
+
+// tracksTTL reports whether reads need the key's PTTL.
+func (r *redisCache[T]) tracksTTL() bool {
+	return r.refresh.Ahead > 0 || r.refresh.StaleTTL > 0
+}
+
+// lookupTTL returns the cached value and its remaining logical lifetime,
+// which is negative once the value is stale. It returns ErrNotFound on a miss
+// or errAbsent when the key holds the absent marker. PTTL is only fetched
+// when Refresh needs it.
+func (r *redisCache[T]) lookupTTL(ctx context.Context, key string) (T, time.Duration, error) {
 	var zero T
-	data, err := r.client.Get(ctx, r.key(key)).Bytes()
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return zero, 0, err
+	}
+	rk := ks + key
+	remaining := noExpiry
+
+	var data []byte
+	if r.tracksTTL() {
+		pipe := r.client.Pipeline()
+		getCmd := pipe.Get(ctx, rk)
+		ttlCmd := pipe.PTTL(ctx, rk)
+		_, _ = pipe.Exec(ctx)
+		data, err = getCmd.Bytes()
+		if pttl := ttlCmd.Val(); ttlCmd.Err() == nil && pttl > 0 {
+			remaining = pttl - r.refresh.StaleTTL
+		}
+	} else {
+		data, err = r.client.Get(ctx, rk).Bytes()
+	}
 	if err != nil {
 		if err == redis.Nil {
-			return zero, ErrNotFound
+			return zero, 0, ErrNotFound
 		}
-		return zero, fmt.Errorf("cache: redis get: %w", err)
+		return zero, 0, fmt.Errorf("cache: redis get: %w", err)
+	}
+	if bytes.Equal(data, r.marker) {
+		return zero, 0, errAbsent
 	}
 
 	var v T
 	if err := r.codec.Unmarshal(data, &v); err != nil {
-		return zero, fmt.Errorf("cache: unmarshal: %w", err)
+		// Undecodable values, e.g. plaintext left over from before encryption
+		// was enabled, are misses so GetOrLoad reloads and overwrites them.
+		return zero, 0, ErrNotFound
+	}
+	return v, remaining, nil
+}
+
+// lookup is lookupTTL for plain reads: stale values count as misses.
+func (r *redisCache[T]) lookup(ctx context.Context, key string) (T, error) {
+	v, remaining, err := r.lookupTTL(ctx, key)
+	if err == nil && remaining <= 0 {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return v, err
+}
+
+// record counts the outcome of a lookup as a hit or a miss; Redis errors
+// count as neither.
+func (r *redisCache[T]) record(key string, err error) {
+	switch {
+	case err == nil || errors.Is(err, errAbsent):
+		r.stats.hit(key, LayerRemote)
+	case errors.Is(err, ErrNotFound):
+		r.stats.miss(key)
+	}
+}
+
+func (r *redisCache[T]) Get(ctx context.Context, key string) (T, error) {
+	v, err := r.lookup(ctx, key)
+	r.record(key, err)
+	if errors.Is(err, errAbsent) {
+		return v, ErrNotFound
 	}
-	return v, nil
+	return v, err
 }
 
 func (r *redisCache[T]) Set(ctx context.Context, key string, value T, opts ...Option) error {
@@ -82,40 +202,100 @@ func (r *redisCache[T]) Set(ctx context.Context, key string, value T, opts ...Op
 	if err != nil {
 		return fmt.Errorf("cache: marshal: %w", err)
 	}
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return err
+	}
 
-	ttl := r.ttl(opts)
-	if err := r.client.Set(ctx, r.key(key), data, ttl).Err(); err != nil {
+	o := applyOptions(opts)
+	rk, ttl := ks+key, r.expiry(r.ttl(opts))
+	if len(o.Tags) == 0 {
+		if err := r.client.Set(ctx, rk, data, ttl).Err(); err != nil {
+			return fmt.Errorf("cache: redis set: %w", err)
+		}
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	pipe.Set(ctx, rk, data, ttl)
+	addTags(ctx, pipe, ks, o.Tags, []string{rk}, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("cache: redis set: %w", err)
 	}
 	return nil
 }
 
+// setAbsent stores the absent marker for key, if negative caching is enabled.
+func (r *redisCache[T]) setAbsent(ctx context.Context, key string) error {
+	if !r.negative.enabled() {
+		return nil
+	}
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Set(ctx, ks+key, r.marker, r.negative.TTL).Err(); err != nil {
+		return fmt.Errorf("cache: redis set absent: %w", err)
+	}
+	return nil
+}
+
 func (r *redisCache[T]) Delete(ctx context.Context, key string) error {
-	if err := r.client.Del(ctx, r.key(key)).Err(); err != nil {
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Del(ctx, ks+key).Err(); err != nil {
 		return fmt.Errorf("cache: redis del: %w", err)
 	}
 	return nil
 }
 
 func (r *redisCache[T]) GetOrLoad(ctx context.Context, key string, loader LoaderFunc[T]) (T, error) {
-	v, err := r.Get(ctx, key)
-	if err == nil {
-		return v, nil
-	}
-	if err != ErrNotFound {
-		var zero T
-		return zero, err
+	v, err := r.getOrLoad(ctx, key, loader)
+	if errors.Is(err, errAbsent) {
+		return v, ErrNotFound
 	}
+	return v, err
+}
 
-	result, err, _ := r.sf.Do(key, func() (interface{}, error) {
-		val, err := loader(ctx, key)
-		if err != nil {
-			return nil, err
+// getOrLoad is GetOrLoad returning errAbsent for keys that do not exist.
+func (r *redisCache[T]) getOrLoad(ctx context.Context, key string, loader LoaderFunc[T]) (T, error) {
+	v, remaining, err := r.lookupTTL(ctx, key)
+	if err == nil && remaining <= 0 {
+		r.stats.miss(key) // stale
+	} else {
+		r.record(key, err)
+	}
+	switch {
+	case err == nil && remaining > 0:
+		if r.refresh.due(remaining) {
+			r.refreshAhead(ctx, key, loader)
 		}
-		if setErr := r.Set(ctx, key, val); setErr != nil {
-			return val, fmt.Errorf("cache: loader succeeded but set failed: %w", setErr)
+		return v, nil
+	case err == nil:
+		// Stale: reload now and fall back to the stale value if that fails.
+		nv, err := r.load(ctx, key, loader)
+		if err != nil && !errors.Is(err, errAbsent) {
+			return v, nil
 		}
-		return val, nil
+		return nv, err
+	case errors.Is(err, ErrNotFound):
+		return r.load(ctx, key, loader)
+	default:
+		return v, err
+	}
+}
+
+// load calls loader once per key across concurrent callers (and, with Lock,
+// across instances) and stores the result, or the absent marker when the
+// loader reports "not found".
+func (r *redisCache[T]) load(ctx context.Context, key string, loader LoaderFunc[T]) (T, error) {
+	loader = timedLoader(r.stats, loader, r.negative.notFound)
+	result, err, _ := r.sf.Do(key, func() (interface{}, error) {
+		return r.lockedLoad(ctx, key, func() (interface{}, error) {
+			return r.loadOnce(ctx, key, loader)
+		})
 	})
 	if err != nil {
 		var zero T
@@ -124,6 +304,243 @@ func (r *redisCache[T]) GetOrLoad(ctx context.Context, key string, loader Loader
 	return result.(T), nil
 }
 
+// loadOnce calls loader and stores its result.
+func (r *redisCache[T]) loadOnce(ctx context.Context, key string, loader LoaderFunc[T]) (interface{}, error) {
+	val, err := loader(ctx, key)
+	if err != nil {
+		if r.negative.enabled() && r.negative.notFound(err) {
+			if setErr := r.setAbsent(ctx, key); setErr != nil {
+				return nil, setErr
+			}
+			return nil, errAbsent
+		}
+		return nil, err
+	}
+	if setErr := r.Set(ctx, key, val); setErr != nil {
+		return val, fmt.Errorf("cache: loader succeeded but set failed: %w", setErr)
+	}
+	return val, nil
+}
+
+// refreshAhead reloads key in the background unless a refresh is already running.
+func (r *redisCache[T]) refreshAhead(ctx context.Context, key string, loader LoaderFunc[T]) {
+	if _, busy := r.refreshing.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		defer r.refreshing.Delete(key)
+		_, _ = r.load(ctx, key, loader)
+	})
+}
+
+// lookupMany fetches keys with one MGET per cluster slot, pipelined. It
+// returns the found values and the keys holding the absent marker; stale
+// values count as misses.
+func (r *redisCache[T]) lookupMany(ctx context.Context, keys []string) (map[string]T, []string, error) {
+	found := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return found, nil, nil
+	}
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byRedisKey := make(map[string]string, len(keys))
+	redisKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		rk := ks + k
+		if _, ok := byRedisKey[rk]; !ok {
+			byRedisKey[rk] = k
+			redisKeys = append(redisKeys, rk)
+		}
+	}
+
+	groups := groupBySlot(r.client, redisKeys)
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(groups))
+	for i, group := range groups {
+		cmds[i] = pipe.MGet(ctx, group...)
+	}
+	var ttlCmds map[string]*redis.DurationCmd
+	if r.refresh.StaleTTL > 0 {
+		ttlCmds = make(map[string]*redis.DurationCmd, len(redisKeys))
+		for _, rk := range redisKeys {
+			ttlCmds[rk] = pipe.PTTL(ctx, rk)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, nil, fmt.Errorf("cache: redis mget: %w", err)
+	}
+
+	var absent []string
+	for i, cmd := range cmds {
+		for j, raw := range cmd.Val() {
+			s, ok := raw.(string)
+			if !ok {
+				continue
+			}
+			rk := groups[i][j]
+			key := byRedisKey[rk]
+			data := []byte(s)
+			if bytes.Equal(data, r.marker) {
+				absent = append(absent, key)
+				continue
+			}
+			if cmd, ok := ttlCmds[rk]; ok && cmd.Val() > 0 && cmd.Val() <= r.refresh.StaleTTL {
+				continue
+			}
+			var v T
+			if err := r.codec.Unmarshal(data, &v); err != nil {
+				continue // undecodable: a miss, as in lookupTTL
+			}
+			found[key] = v
+		}
+	}
+	return found, absent, nil
+}
+
+// recordMany counts a batch lookup per key.
+func (r *redisCache[T]) recordMany(keys []string, found map[string]T, absent []string) {
+	for _, key := range missingKeys(keys, found, absent) {
+		r.stats.miss(key)
+	}
+	for key := range found {
+		r.stats.hit(key, LayerRemote)
+	}
+	for _, key := range absent {
+		r.stats.hit(key, LayerRemote)
+	}
+}
+
+func (r *redisCache[T]) GetMany(ctx context.Context, keys []string) (map[string]T, error) {
+	found, absent, err := r.lookupMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	r.recordMany(keys, found, absent)
+	return found, nil
+}
+
+// SetMany writes all items in a single pipeline; go-redis routes each SET to
+// its node when the client is a cluster.
+func (r *redisCache[T]) SetMany(ctx context.Context, items map[string]T, opts ...Option) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	o := applyOptions(opts)
+	ttl := r.ttl(opts)
+	pipe := r.client.Pipeline()
+	redisKeys := make([]string, 0, len(items))
+	var maxExpiry time.Duration
+	for key, value := range items {
+		data, err := r.codec.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("cache: marshal %q: %w", key, err)
+		}
+		expiry := r.expiry(ttl)
+		maxExpiry = max(maxExpiry, expiry)
+		pipe.Set(ctx, ks+key, data, expiry)
+		redisKeys = append(redisKeys, ks+key)
+	}
+	addTags(ctx, pipe, ks, o.Tags, redisKeys, maxExpiry)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("cache: redis set many: %w", err)
+	}
+	return nil
+}
+
+// setAbsentMany stores the absent marker for keys, if negative caching is enabled.
+func (r *redisCache[T]) setAbsentMany(ctx context.Context, keys []string) error {
+	if !r.negative.enabled() || len(keys) == 0 {
+		return nil
+	}
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.Pipeline()
+	for _, key := range keys {
+		pipe.Set(ctx, ks+key, r.marker, r.negative.TTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("cache: redis set absent: %w", err)
+	}
+	return nil
+}
+
+func (r *redisCache[T]) DeleteMany(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	redisKeys := make([]string, len(keys))
+	for i, k := range keys {
+		redisKeys[i] = ks + k
+	}
+
+	pipe := r.client.Pipeline()
+	for _, group := range groupBySlot(r.client, redisKeys) {
+		pipe.Del(ctx, group...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("cache: redis del many: %w", err)
+	}
+	return nil
+}
+
+func (r *redisCache[T]) GetOrLoadMany(ctx context.Context, keys []string, loader BatchLoaderFunc[T]) (map[string]T, error) {
+	found, absent, err := r.lookupMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	r.recordMany(keys, found, absent)
+
+	missing := missingKeys(keys, found, absent)
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	loaded, err := timedBatchLoader(r.stats, loader)(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	toSet := make(map[string]T, len(loaded))
+	var notFound []string
+	for _, key := range missing {
+		if v, ok := loaded[key]; ok {
+			toSet[key] = v
+			found[key] = v
+		} else {
+			notFound = append(notFound, key)
+		}
+	}
+
+	if err := r.SetMany(ctx, toSet); err != nil {
+		return found, fmt.Errorf("cache: loader succeeded but set failed: %w", err)
+	}
+	if err := r.setAbsentMany(ctx, notFound); err != nil {
+		return found, err
+	}
+	return found, nil
+}
+
+func (r *redisCache[T]) Stats() Stats {
+	return r.stats.snapshot()
+}
+
 func (r *redisCache[T]) Close() error {
 	return nil
 }
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const clusterSlots = 16384
+
+// clusterSlot returns the Redis Cluster hash slot of key, honouring {hash tags}.
+func clusterSlot(key string) int {
+	if s := strings.IndexByte(key, '{'); s >= 0 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			key = key[s+1 : s+1+e]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 implements CRC16-CCITT (XMODEM) as used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// groupBySlot splits keys so multi-key commands never span cluster slots.
+// Non-cluster clients get a single group with every key.
+func groupBySlot(client redis.UniversalClient, keys []string) [][]string {
+	if _, ok := client.(*redis.ClusterClient); !ok {
+		return [][]string{keys}
+	}
+
+	index := make(map[int]int)
+	var groups [][]string
+	for _, k := range keys {
+		slot := clusterSlot(k)
+		i, ok := index[slot]
+		if !ok {
+			i = len(groups)
+			index[slot] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], k)
+	}
+	return groups
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time snapshot of a cache's activity since creation.
+type Stats struct {
+	Hits       uint64 // lookups answered by the cache, including cached "not found"
+	Misses     uint64 // lookups that were not in the cache
+	LocalHits  uint64 // hits served from memory (the L1 of a two-level cache)
+	RemoteHits uint64 // hits served from Redis
+	Loads      uint64 // loader invocations; a batch loader call counts once
+	LoadErrors uint64 // loader invocations that failed with something other than "not found"
+	LoadTime   time.Duration
+	Evictions  uint64 // entries dropped by size or expiry (memory only)
+}
+
+// HitRatio returns Hits / (Hits + Misses), or 0 before the first lookup.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// AverageLoadTime returns LoadTime / Loads, or 0 before the first load.
+func (s Stats) AverageLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+// Layer identifies where a hit was served from.
+type Layer int
+
+const (
+	LayerLocal Layer = iota
+	LayerRemote
+)
+
+func (l Layer) String() string {
+	if l == LayerRemote {
+		return "remote"
+	}
+	return "local"
+}
+
+// EventKind is the type of an Event.
+type EventKind int
+
+const (
+	EventHit EventKind = iota
+	EventMiss
+	EventLoad
+	EventEviction
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventLoad:
+		return "load"
+	case EventEviction:
+		return "eviction"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a single cache event passed to a Hook.
+type Event struct {
+	Kind     EventKind
+	Key      string        // empty for batch loads
+	Layer    Layer         // EventHit only
+	Duration time.Duration // EventLoad only
+	Err      error         // EventLoad only; nil when the loader succeeded or reported "not found"
+}
+
+// Hook observes cache events, e.g. to export metrics. OnEvent is called
+// synchronously (evictions from the memory cache's maintenance goroutine)
+// and must not block.
+type Hook interface {
+	OnEvent(e Event)
+}
+
+// HookFunc adapts a function to the Hook interface.
+type HookFunc func(e Event)
+
+func (f HookFunc) OnEvent(e Event) {
+	f(e)
+}
+
+// recorder counts cache events and forwards them to an optional Hook.
+type recorder struct {
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	localHits  atomic.Uint64
+	remoteHits atomic.Uint64
+	loads      atomic.Uint64
+	loadErrors atomic.Uint64
+	loadNanos  atomic.Int64
+	evictions  atomic.Uint64
+	hook       Hook
+}
+
+func newRecorder(hook Hook) *recorder {
+	return &recorder{hook: hook}
+}
+
+func (r *recorder) emit(e Event) {
+	if r.hook != nil {
+		r.hook.OnEvent(e)
+	}
+}
+
+func (r *recorder) hit(key string, layer Layer) {
+	r.hits.Add(1)
+	if layer == LayerRemote {
+		r.remoteHits.Add(1)
+	} else {
+		r.localHits.Add(1)
+	}
+	r.emit(Event{Kind: EventHit, Key: key, Layer: layer})
+}
+
+func (r *recorder) miss(key string) {
+	r.misses.Add(1)
+	r.emit(Event{Kind: EventMiss, Key: key})
+}
+
+// load records a loader invocation that started at start. Errors matching
+// notFound are not counted as load errors.
+func (r *recorder) load(key string, start time.Time, err error, notFound func(error) bool) {
+	d := time.Since(start)
+	r.loads.Add(1)
+	r.loadNanos.Add(int64(d))
+	if err != nil && notFound(err) {
+		err = nil
+	}
+	if err != nil {
+		r.loadErrors.Add(1)
+	}
+	r.emit(Event{Kind: EventLoad, Key: key, Duration: d, Err: err})
+}
+
+func (r *recorder) evict(key string) {
+	r.evictions.Add(1)
+	r.emit(Event{Kind: EventEviction, Key: key})
+}
+
+// timedLoader wraps loader so every invocation is recorded on r.
+func timedLoader[T any](r *recorder, loader LoaderFunc[T], notFound func(error) bool) LoaderFunc[T] {
+	return func(ctx context.Context, key string) (T, error) {
+		start := time.Now()
+		v, err := loader(ctx, key)
+		r.load(key, start, err, notFound)
+		return v, err
+	}
+}
+
+// timedBatchLoader wraps loader so every invocation is recorded on r.
+func timedBatchLoader[T any](r *recorder, loader BatchLoaderFunc[T]) BatchLoaderFunc[T] {
+	return func(ctx context.Context, keys []string) (map[string]T, error) {
+		start := time.Now()
+		values, err := loader(ctx, keys)
+		r.load("", start, err, func(error) bool { return false })
+		return values, err
+	}
+}
+
+func (r *recorder) snapshot() Stats {
+	return Stats{
+		Hits:       r.hits.Load(),
+		Misses:     r.misses.Load(),
+		LocalHits:  r.localHits.Load(),
+		RemoteHits: r.remoteHits.Load(),
+		Loads:      r.loads.Load(),
+		LoadErrors: r.loadErrors.Load(),
+		LoadTime:   time.Duration(r.loadNanos.Load()),
+		Evictions:  r.evictions.Load(),
+	}
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TagInvalidator is implemented by the Redis and two-level caches:
+//
+//	if inv, ok := c.(cache.TagInvalidator); ok {
+//		inv.InvalidateTag(ctx, "user:42")
+//	}
+type TagInvalidator interface {
+	// InvalidateTag deletes every key that was Set with WithTags(tag).
+	InvalidateTag(ctx context.Context, tag string) error
+	// Flush logically drops every key under the cache's Prefix in O(1).
+	// It requires RedisConfig.Versioned.
+	Flush(ctx context.Context) error
+}
+
+// ErrNotVersioned is returned by Flush on caches without RedisConfig.Versioned.
+var ErrNotVersioned = errors.New("cache: Flush requires RedisConfig.Versioned")
+
+const (
+	tagKeyPrefix   = "#tag:"
+	versionKeyName = "#version"
+	// tagDeleteChunk bounds the number of members popped, and keys deleted,
+	// per round trip when invalidating a tag.
+	tagDeleteChunk = 500
+)
+
+// tagScript adds members to a tag set and extends the set's TTL so it
+// outlives every member. KEYS[1] = tag set, ARGV[1] = TTL in ms, ARGV[2..] = members.
+var tagScript = redis.NewScript(`
+redis.call('SADD', KEYS[1], unpack(ARGV, 2))
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[1]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// addTags records redisKeys under every tag in pipe. The tag sets live in
+// the same keyspace as the values, so a namespace flush drops them too.
+func addTags(ctx context.Context, pipe redis.Pipeliner, ks string, tags []string, redisKeys []string, ttl time.Duration) {
+	if len(tags) == 0 || len(redisKeys) == 0 {
+		return
+	}
+	args := make([]interface{}, 0, len(redisKeys)+1)
+	args = append(args, ttl.Milliseconds())
+	for _, rk := range redisKeys {
+		args = append(args, rk)
+	}
+	for _, tag := range tags {
+		tagScript.Eval(ctx, pipe, []string{ks + tagKeyPrefix + tag}, args...)
+	}
+}
+
+func (r *redisCache[T]) InvalidateTag(ctx context.Context, tag string) error {
+	_, err := r.invalidateTag(ctx, tag)
+	return err
+}
+
+// invalidateTag deletes the keys tagged with tag and returns them as cache keys.
+// Members are taken with SPOP, so a key tagged while the invalidation runs is
+// either popped and deleted here or stays in the set for the next call; it is
+// never dropped from the set without its value being deleted.
+func (r *redisCache[T]) invalidateTag(ctx context.Context, tag string) ([]string, error) {
+	ks, err := r.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tagKey := ks + tagKeyPrefix + tag
+
+	var keys []string
+	for {
+		members, err := r.client.SPopN(ctx, tagKey, tagDeleteChunk).Result()
+		if err != nil {
+			return keys, fmt.Errorf("cache: redis spop: %w", err)
+		}
+		if len(members) > 0 {
+			pipe := r.client.Pipeline()
+			for _, group := range groupBySlot(r.client, members) {
+				pipe.Del(ctx, group...)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				// The popped members are gone from the set; put them back so
+				// a retry still finds them.
+				retag := r.client.Pipeline()
+				addTags(ctx, retag, ks, []string{tag}, members, r.expiry(r.ttl(nil)))
+				_, _ = retag.Exec(context.WithoutCancel(ctx))
+				return keys, fmt.Errorf("cache: redis del tagged: %w", err)
+			}
+		}
+		for _, rk := range members {
+			if k, ok := strings.CutPrefix(rk, ks); ok {
+				keys = append(keys, k)
+			}
+		}
+		if len(members) < tagDeleteChunk {
+			return keys, nil
+		}
+	}
+}
+
+func (r *redisCache[T]) Flush(ctx context.Context) error {
+	if r.ns == nil {
+		return ErrNotVersioned
+	}
+	return r.ns.bump(ctx)
+}
+
+func (t *twoLevelCache[T]) InvalidateTag(ctx context.Context, tag string) error {
+	keys, err := t.remote.invalidateTag(ctx, tag)
+	if err != nil {
+		return err
+	}
+	_ = t.local.DeleteMany(ctx, keys)
+	return t.publish(ctx, keys...)
+}
+
+func (t *twoLevelCache[T]) Flush(ctx context.Context) error {
+	if err := t.remote.Flush(ctx); err != nil {
+		return err
+	}
+	t.local.clear()
+	if t.inv == nil {
+		return nil
+	}
+	return t.inv.publishFlush(ctx)
+}
@@ -4,106 +4,421 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/Cotary/go-lib/log"
 	"golang.org/x/sync/singleflight"
 )
 
 type TwoLevelConfig struct {
-	Local  MemoryConfig
-	Remote RedisConfig
+	Local        MemoryConfig
+	Remote       RedisConfig
+	Invalidation InvalidationConfig
+	Breaker      BreakerConfig
+	// Hook observes events of the two-level cache as a whole; hooks set on
+	// Local and Remote are ignored.
+	Hook Hook
 }
 
 type twoLevelCache[T any] struct {
-	local  Cache[T]
-	remote Cache[T]
-	sf     singleflight.Group
+	local   *memoryCache[T]
+	remote  *redisCache[T]
+	inv     *invalidator
+	breaker *breaker // nil unless Breaker.FailureThreshold > 0
+	stats   *recorder
+	sf      singleflight.Group
 }
 
 func NewTwoLevel[T any](cfg TwoLevelConfig) (Cache[T], error) {
-	local, err := NewMemory[T](cfg.Local)
+	// L1 shares the two-level recorder so its evictions are counted; every
+	// lookup is recorded here rather than by the layers.
+	stats := newRecorder(cfg.Hook)
+	local, err := newMemoryCache[T](cfg.Local, stats)
 	if err != nil {
 		return nil, fmt.Errorf("cache: create local cache: %w", err)
 	}
 
-	remote, err := NewRedis[T](cfg.Remote)
+	remote, err := newRedisCache[T](cfg.Remote, newRecorder(nil))
 	if err != nil {
 		local.Close()
 		return nil, fmt.Errorf("cache: create remote cache: %w", err)
 	}
 
-	return &twoLevelCache[T]{
+	t := &twoLevelCache[T]{
 		local:  local,
 		remote: remote,
-	}, nil
+		stats:  stats,
+	}
+	if cfg.Invalidation.Channel != "" {
+		t.inv = newInvalidator(cfg.Remote.Client, cfg.Invalidation, t.evictLocal, t.flushLocal)
+	}
+	if cfg.Breaker.FailureThreshold > 0 {
+		t.breaker = newBreaker(cfg.Breaker, cfg.Remote.Prefix, t.purgeDirty)
+	}
+	return t, nil
+}
+
+// remoteUp reports whether this call may use the remote layer.
+func (t *twoLevelCache[T]) remoteUp() bool {
+	return t.breaker == nil || t.breaker.allow()
+}
+
+// degraded reports the outcome of a remote call to the breaker and whether
+// the call should carry on with the local layer alone.
+func (t *twoLevelCache[T]) degraded(ctx context.Context, err error) bool {
+	return t.breaker != nil && t.breaker.report(ctx, err)
+}
+
+// purgeDirty deletes from Redis the keys that were written only locally
+// while it was failing, once it is reachable again.
+func (t *twoLevelCache[T]) purgeDirty(ctx context.Context, keys []string, overflow bool) error {
+	if overflow {
+		if t.remote.ns != nil {
+			return t.remote.Flush(ctx)
+		}
+		log.WithContext(ctx).WithField("cache", t.remote.prefix).
+			Warn("cache: too many keys written during outage, Redis may serve stale values until they expire")
+	}
+	if err := t.remote.DeleteMany(ctx, keys); err != nil {
+		return err
+	}
+	return t.publish(ctx, keys...)
+}
+
+// absent converts a loader error to errAbsent when it means "not found" and
+// negative caching is enabled, as the remote layer does.
+func (t *twoLevelCache[T]) absent(err error) error {
+	if err != nil && t.remote.negative.enabled() && t.remote.negative.notFound(err) {
+		return errAbsent
+	}
+	return err
+}
+
+// evictLocal drops keys changed by another instance from the local layer.
+func (t *twoLevelCache[T]) evictLocal(keys []string) {
+	for _, key := range keys {
+		_ = t.local.Delete(context.Background(), key)
+	}
+}
+
+// flushLocal drops the whole local layer and, for a versioned remote, the
+// cached namespace version so the next read picks up a Flush right away.
+func (t *twoLevelCache[T]) flushLocal() {
+	t.local.clear()
+	if t.remote.ns != nil {
+		t.remote.ns.expire()
+	}
+}
+
+// publish notifies other instances that keys changed, if invalidation is enabled.
+func (t *twoLevelCache[T]) publish(ctx context.Context, keys ...string) error {
+	if t.inv == nil {
+		return nil
+	}
+	return t.inv.publish(ctx, keys...)
 }
 
 func (t *twoLevelCache[T]) Get(ctx context.Context, key string) (T, error) {
-	v, err := t.local.Get(ctx, key)
+	v, err := t.local.lookup(key)
 	if err == nil {
+		t.stats.hit(key, LayerLocal)
 		return v, nil
 	}
-	if !errors.Is(err, ErrNotFound) {
-		return v, err
+	if errors.Is(err, errAbsent) {
+		t.stats.hit(key, LayerLocal)
+		return v, ErrNotFound
 	}
 
-	v, err = t.remote.Get(ctx, key)
+	if !t.remoteUp() {
+		t.stats.miss(key)
+		return v, ErrNotFound
+	}
+	v, err = t.remote.lookup(ctx, key)
+	if t.degraded(ctx, err) {
+		t.stats.miss(key)
+		var zero T
+		return zero, ErrNotFound
+	}
 	if err == nil {
+		t.stats.hit(key, LayerRemote)
 		_ = t.local.Set(ctx, key, v)
 		return v, nil
 	}
+	if errors.Is(err, errAbsent) {
+		t.stats.hit(key, LayerRemote)
+		t.local.setAbsent(key)
+		return v, ErrNotFound
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.stats.miss(key)
+	}
 	return v, err
 }
 
 func (t *twoLevelCache[T]) Set(ctx context.Context, key string, value T, opts ...Option) error {
-	if err := t.remote.Set(ctx, key, value, opts...); err != nil {
-		return err
+	if t.remoteUp() {
+		err := t.remote.Set(ctx, key, value, opts...)
+		if !t.degraded(ctx, err) {
+			if err != nil {
+				return err
+			}
+			_ = t.local.Set(ctx, key, value, opts...)
+			return t.publish(ctx, key)
+		}
 	}
 	_ = t.local.Set(ctx, key, value, opts...)
+	t.breaker.markDirty(key)
 	return nil
 }
 
 func (t *twoLevelCache[T]) Delete(ctx context.Context, key string) error {
-	remoteErr := t.remote.Delete(ctx, key)
+	if t.remoteUp() {
+		remoteErr := t.remote.Delete(ctx, key)
+		if !t.degraded(ctx, remoteErr) {
+			_ = t.local.Delete(ctx, key)
+			if remoteErr != nil {
+				return remoteErr
+			}
+			return t.publish(ctx, key)
+		}
+	}
 	_ = t.local.Delete(ctx, key)
-	return remoteErr
+	t.breaker.markDirty(key)
+	return nil
 }
 
 func (t *twoLevelCache[T]) GetOrLoad(ctx context.Context, key string, loader LoaderFunc[T]) (T, error) {
-	v, err := t.local.Get(ctx, key)
+	v, err := t.local.lookup(key)
 	if err == nil {
+		t.stats.hit(key, LayerLocal)
 		return v, nil
 	}
+	if errors.Is(err, errAbsent) {
+		t.stats.hit(key, LayerLocal)
+		return v, ErrNotFound
+	}
 
+	// outcome tells every caller sharing the flight whether L2 answered or
+	// the loader ran, so each one is recorded as a hit or a miss.
+	type outcome struct {
+		val    T
+		loaded bool
+	}
+	timed := timedLoader(t.stats, loader, t.remote.negative.notFound)
 	result, err, _ := t.sf.Do(key, func() (interface{}, error) {
-		// Check remote first (inside singleflight to avoid thundering herd on Redis)
-		val, err := t.remote.Get(ctx, key)
-		if err == nil {
-			return val, nil
-		}
-		if !errors.Is(err, ErrNotFound) {
-			return nil, err
+		if !t.remoteUp() {
+			val, err := timed(ctx, key)
+			return outcome{val: val, loaded: true}, t.absent(err)
 		}
 
-		val, err = loader(ctx, key)
-		if err != nil {
-			return nil, err
+		// Check remote first (inside singleflight to avoid thundering herd on Redis).
+		// The remote layer handles negative caching, refresh-ahead and stale values.
+		// A refresh-ahead may call the loader in the background, hence the mutex.
+		var (
+			mu        sync.Mutex
+			loaded    bool
+			loadedVal T
+			loadErr   error
+		)
+		val, err := t.remote.getOrLoad(ctx, key, func(ctx context.Context, key string) (T, error) {
+			v, err := timed(ctx, key)
+			mu.Lock()
+			loaded, loadedVal, loadErr = true, v, err
+			mu.Unlock()
+			return v, err
+		})
+		mu.Lock()
+		o := outcome{val: loadedVal, loaded: loaded}
+		remoteErr := err
+		if loaded && loadErr != nil && errors.Is(err, loadErr) {
+			remoteErr = nil // the loader failed, not Redis
 		}
+		mu.Unlock()
 
-		if setErr := t.remote.Set(ctx, key, val); setErr != nil {
-			return val, fmt.Errorf("cache: loader succeeded but remote set failed: %w", setErr)
+		if !t.degraded(ctx, remoteErr) {
+			o.val = val
+			return o, err
 		}
-		return val, nil
+		// Redis failed: keep what the loader returned, or call it now.
+		if !o.loaded {
+			o.val, loadErr = timed(ctx, key)
+			o.loaded = true
+		}
+		return o, t.absent(loadErr)
 	})
+	o := result.(outcome)
+	switch {
+	case o.loaded:
+		t.stats.miss(key)
+	case err == nil || errors.Is(err, errAbsent):
+		t.stats.hit(key, LayerRemote)
+	}
+
+	if errors.Is(err, errAbsent) {
+		t.local.setAbsent(key)
+		var zero T
+		return zero, ErrNotFound
+	}
 	if err != nil {
 		var zero T
 		return zero, err
 	}
 
-	v = result.(T)
-	_ = t.local.Set(ctx, key, v)
-	return v, nil
+	_ = t.local.Set(ctx, key, o.val)
+	return o.val, nil
+}
+
+// lookupMany resolves keys from L1, then L2 (promoting what it finds into
+// L1), recording a hit or miss per key. It returns the found values and the
+// keys that are neither found nor cached as absent.
+func (t *twoLevelCache[T]) lookupMany(ctx context.Context, keys []string) (map[string]T, []string, error) {
+	found := make(map[string]T, len(keys))
+	var absent []string
+	for _, key := range keys {
+		v, err := t.local.lookup(key)
+		if err == nil {
+			found[key] = v
+			t.stats.hit(key, LayerLocal)
+		} else if errors.Is(err, errAbsent) {
+			absent = append(absent, key)
+			t.stats.hit(key, LayerLocal)
+		}
+	}
+
+	missing := missingKeys(keys, found, absent)
+	if len(missing) == 0 {
+		return found, nil, nil
+	}
+
+	var remote map[string]T
+	var remoteAbsent []string
+	if t.remoteUp() {
+		var err error
+		remote, remoteAbsent, err = t.remote.lookupMany(ctx, missing)
+		if t.degraded(ctx, err) {
+			remote, remoteAbsent = nil, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+	t.fillLocal(ctx, remote, remoteAbsent)
+	for key, v := range remote {
+		found[key] = v
+		t.stats.hit(key, LayerRemote)
+	}
+	for _, key := range remoteAbsent {
+		t.stats.hit(key, LayerRemote)
+	}
+
+	missing = missingKeys(missing, remote, remoteAbsent)
+	for _, key := range missing {
+		t.stats.miss(key)
+	}
+	return found, missing, nil
+}
+
+// fillLocal promotes values and absent markers from L2 into L1.
+func (t *twoLevelCache[T]) fillLocal(ctx context.Context, values map[string]T, absent []string) {
+	for key, v := range values {
+		_ = t.local.Set(ctx, key, v)
+	}
+	for _, key := range absent {
+		t.local.setAbsent(key)
+	}
+}
+
+func (t *twoLevelCache[T]) GetMany(ctx context.Context, keys []string) (map[string]T, error) {
+	found, _, err := t.lookupMany(ctx, keys)
+	return found, err
+}
+
+func (t *twoLevelCache[T]) SetMany(ctx context.Context, items map[string]T, opts ...Option) error {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	if t.remoteUp() {
+		err := t.remote.SetMany(ctx, items, opts...)
+		if !t.degraded(ctx, err) {
+			if err != nil {
+				return err
+			}
+			_ = t.local.SetMany(ctx, items, opts...)
+			return t.publish(ctx, keys...)
+		}
+	}
+	_ = t.local.SetMany(ctx, items, opts...)
+	t.breaker.markDirty(keys...)
+	return nil
+}
+
+func (t *twoLevelCache[T]) DeleteMany(ctx context.Context, keys []string) error {
+	if t.remoteUp() {
+		remoteErr := t.remote.DeleteMany(ctx, keys)
+		if !t.degraded(ctx, remoteErr) {
+			_ = t.local.DeleteMany(ctx, keys)
+			if remoteErr != nil {
+				return remoteErr
+			}
+			return t.publish(ctx, keys...)
+		}
+	}
+	_ = t.local.DeleteMany(ctx, keys)
+	t.breaker.markDirty(keys...)
+	return nil
+}
+
+// GetOrLoadMany resolves keys from L1, then L2, then calls loader once for the
+// remaining keys. Unlike GetOrLoad, concurrent batches are not coalesced.
+func (t *twoLevelCache[T]) GetOrLoadMany(ctx context.Context, keys []string, loader BatchLoaderFunc[T]) (map[string]T, error) {
+	found, missing, err := t.lookupMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	loaded, err := timedBatchLoader(t.stats, loader)(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	toSet := make(map[string]T, len(loaded))
+	var notFound []string
+	for _, key := range missing {
+		if v, ok := loaded[key]; ok {
+			toSet[key] = v
+			found[key] = v
+		} else {
+			notFound = append(notFound, key)
+		}
+	}
+
+	if t.remoteUp() {
+		err := t.remote.SetMany(ctx, toSet)
+		if err == nil && t.remote.negative.enabled() {
+			err = t.remote.setAbsentMany(ctx, notFound)
+		}
+		if !t.degraded(ctx, err) && err != nil {
+			return found, fmt.Errorf("cache: loader succeeded but remote set failed: %w", err)
+		}
+	}
+	if t.remote.negative.enabled() {
+		t.fillLocal(ctx, nil, notFound)
+	}
+	_ = t.local.SetMany(ctx, toSet)
+	return found, nil
+}
+
+func (t *twoLevelCache[T]) Stats() Stats {
+	return t.stats.snapshot()
 }
 
 func (t *twoLevelCache[T]) Close() error {
+	if t.inv != nil {
+		_ = t.inv.close()
+	}
 	return t.local.Close()
 }
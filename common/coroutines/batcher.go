@@ -0,0 +1,235 @@
+package coroutines
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 批量合并写入。
+//
+// Batcher 把逐条到达的数据攒成批，满足任一条件即交给 flush 处理：
+//   - 攒够 WithBatchSize 条；
+//   - 批中第一条到达后已过 WithBatchLatency。
+//
+// flush 在独立协程中经 SafeFunc 执行，同时运行的 flush 数由 WithFlushConcurrency 限制，
+// 达到上限时攒批协程阻塞，缓冲区写满后 Add 随之阻塞，形成背压。flush 返回 error 时按
+// RetryWithOptions 退避重试，重试耗尽后由 Retry 告警并丢弃该批；需要落盘兜底的场景
+// 请在 flush 内部处理。Close 停止接收新数据，并把缓冲中的数据全部 flush 完再返回。
+
+// ErrBatcherClosed 表示 Batcher 已 Close，不再接收新数据。
+var ErrBatcherClosed = errors.New("batcher closed")
+
+type batchConfig struct {
+	size        int
+	latency     time.Duration
+	concurrency int
+	buffer      int
+	retry       []RetryOption
+}
+
+func defaultBatchConfig() *batchConfig {
+	return &batchConfig{
+		size:        100,
+		latency:     time.Second,
+		concurrency: 1,
+		retry:       []RetryOption{WithMaxRetries(3), WithBackoff(100*time.Millisecond, 5*time.Second)},
+	}
+}
+
+// BatchOption 配置 Batcher 的行为，非法值被忽略。
+type BatchOption func(*batchConfig)
+
+// WithBatchSize 设置每批的最大条数，默认 100。
+func WithBatchSize(n int) BatchOption {
+	return func(c *batchConfig) {
+		if n > 0 {
+			c.size = n
+		}
+	}
+}
+
+// WithBatchLatency 设置一条数据最多等待多久被 flush，默认 1s。
+func WithBatchLatency(d time.Duration) BatchOption {
+	return func(c *batchConfig) {
+		if d > 0 {
+			c.latency = d
+		}
+	}
+}
+
+// WithFlushConcurrency 设置同时运行的 flush 数，默认 1（批与批之间串行、保持顺序）。
+func WithFlushConcurrency(n int) BatchOption {
+	return func(c *batchConfig) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithBatchBuffer 设置 Add 与攒批协程之间的缓冲区长度，默认等于批大小。
+func WithBatchBuffer(n int) BatchOption {
+	return func(c *batchConfig) {
+		if n > 0 {
+			c.buffer = n
+		}
+	}
+}
+
+// WithFlushRetry 追加 flush 失败时的重试选项，默认最多重试 3 次、退避 100ms ~ 5s。
+// 例如 WithFlushRetry(WithMaxRetries(10)) 覆盖重试次数。
+func WithFlushRetry(opts ...RetryOption) BatchOption {
+	return func(c *batchConfig) {
+		c.retry = append(c.retry, opts...)
+	}
+}
+
+// Batcher 把逐条写入合并为批量 flush，详见文件头说明。
+type Batcher[T any] struct {
+	cfg    *batchConfig
+	flush  func(ctx context.Context, batch []T) error
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	in      chan T
+	sem     chan struct{}
+	wg      sync.WaitGroup // 进行中的 flush
+	done    chan struct{}  // 攒批协程退出
+	closing chan struct{}  // Close 时关闭，唤醒阻塞在 Add 中的发送者
+
+	mu      sync.Mutex
+	closed  bool
+	senders sync.WaitGroup // 进行中的 Add，全部离开后才能关闭 in
+	once    sync.Once
+}
+
+// NewBatcher 创建并启动 Batcher。ctx 传给每次 flush（其取消不会让 Batcher 停止，
+// 停止请调用 Close）；flush 拿到的 batch 归其所有，可以保留。
+func NewBatcher[T any](ctx context.Context, flush func(ctx context.Context, batch []T) error, opts ...BatchOption) *Batcher[T] {
+	cfg := defaultBatchConfig()
+	for _, o := range opts {
+		o(cfg)
+	}
+	if cfg.buffer <= 0 {
+		cfg.buffer = cfg.size
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	b := &Batcher[T]{
+		cfg:     cfg,
+		flush:   flush,
+		ctx:     ctx,
+		cancel:  cancel,
+		in:      make(chan T, cfg.buffer),
+		sem:     make(chan struct{}, cfg.concurrency),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+	SafeGo(ctx, func(ctx context.Context) { b.run() })
+	return b
+}
+
+// Add 写入一条数据。缓冲区满时阻塞，直到有空位、ctx 结束或 Batcher 被 Close；
+// Close 之后调用（含阻塞期间被 Close）返回 ErrBatcherClosed。
+func (b *Batcher[T]) Add(ctx context.Context, item T) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBatcherClosed
+	}
+	b.senders.Add(1)
+	b.mu.Unlock()
+	defer b.senders.Done()
+
+	select {
+	case b.in <- item:
+		return nil
+	case <-b.closing:
+		return ErrBatcherClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 停止接收新数据，flush 剩余数据并等待所有 flush 结束。ctx 结束时不再等待，
+// 取消进行中的 flush（含重试退避）并返回 ctx.Err()。可重复调用，
+// 签名与 lifecycle.Hook.OnStop 一致。
+func (b *Batcher[T]) Close(ctx context.Context) error {
+	b.once.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		close(b.closing)
+		b.mu.Unlock()
+		// 不能在发送者阻塞于 in 时关闭 in；发送者已被 closing 唤醒，很快全部离开
+		go func() {
+			b.senders.Wait()
+			close(b.in)
+		}()
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		<-b.done
+		b.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		b.cancel()
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+// run 是攒批协程：按条数或延迟切批，in 关闭后 flush 剩余数据并退出。
+func (b *Batcher[T]) run() {
+	defer close(b.done)
+
+	batch := make([]T, 0, b.cfg.size)
+	timer := time.NewTimer(b.cfg.latency)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case item, ok := <-b.in:
+			if !ok {
+				b.dispatch(batch)
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(b.cfg.latency)
+			}
+			batch = append(batch, item)
+			if len(batch) >= b.cfg.size {
+				timer.Stop()
+				b.dispatch(batch)
+				batch = make([]T, 0, b.cfg.size)
+			}
+		case <-timer.C:
+			b.dispatch(batch)
+			batch = make([]T, 0, b.cfg.size)
+		}
+	}
+}
+
+// dispatch 在并发额度内起协程 flush 一批数据，额度用尽时阻塞。
+func (b *Batcher[T]) dispatch(batch []T) {
+	if len(batch) == 0 {
+		return
+	}
+	b.sem <- struct{}{}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer func() { <-b.sem }()
+		SafeFunc(b.ctx, func(ctx context.Context) {
+			_ = RetryWithOptions(ctx, func(ctx context.Context) error {
+				return b.flush(ctx, batch)
+			}, b.cfg.retry...)
+		})
+	}()
+}
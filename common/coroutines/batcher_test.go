@@ -0,0 +1,178 @@
+package coroutines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (r *batchRecorder) flush(ctx context.Context, batch []int) error {
+	r.mu.Lock()
+	r.batches = append(r.batches, batch)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *batchRecorder) snapshot() [][]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]int(nil), r.batches...)
+}
+
+// TestBatcher_SizeAndLatency 验证攒够条数立即 flush，不足时按延迟 flush。
+func TestBatcher_SizeAndLatency(t *testing.T) {
+	rec := &batchRecorder{}
+	b := NewBatcher(context.Background(), rec.flush, WithBatchSize(3), WithBatchLatency(50*time.Millisecond))
+	defer b.Close(context.Background())
+
+	for i := 1; i <= 4; i++ {
+		if err := b.Add(context.Background(), i); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	waitFor(t, func() bool { return len(rec.snapshot()) == 1 })
+	if got := rec.snapshot()[0]; len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Fatalf("unexpected first batch: %v", got)
+	}
+	waitFor(t, func() bool { return len(rec.snapshot()) == 2 })
+	if got := rec.snapshot()[1]; len(got) != 1 || got[0] != 4 {
+		t.Fatalf("unexpected latency batch: %v", got)
+	}
+}
+
+// TestBatcher_CloseDrains 验证 Close 会 flush 缓冲中的数据，之后 Add 返回 ErrBatcherClosed。
+func TestBatcher_CloseDrains(t *testing.T) {
+	rec := &batchRecorder{}
+	b := NewBatcher(context.Background(), rec.flush, WithBatchSize(100), WithBatchLatency(time.Hour))
+	for i := 0; i < 250; i++ {
+		_ = b.Add(context.Background(), i)
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	total := 0
+	for _, batch := range rec.snapshot() {
+		total += len(batch)
+	}
+	if total != 250 {
+		t.Fatalf("expected 250 items flushed, got %d", total)
+	}
+	if err := b.Add(context.Background(), 1); !errors.Is(err, ErrBatcherClosed) {
+		t.Fatalf("expected ErrBatcherClosed, got %v", err)
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
+
+// TestBatcher_RetryAndPanic 验证 flush 失败会重试，panic 不会拖垮 Batcher。
+func TestBatcher_RetryAndPanic(t *testing.T) {
+	var calls, panics atomic.Int32
+	flush := func(ctx context.Context, batch []int) error {
+		if batch[0] < 0 {
+			panics.Add(1)
+			panic("bad batch")
+		}
+		if calls.Add(1) < 3 {
+			return errors.New("db busy")
+		}
+		return nil
+	}
+	b := NewBatcher(context.Background(), flush, WithBatchSize(1),
+		WithFlushRetry(WithBackoff(time.Millisecond, time.Millisecond), WithRetryAlert(nil)))
+
+	_ = b.Add(context.Background(), -1)
+	_ = b.Add(context.Background(), 1)
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if calls.Load() != 3 || panics.Load() != 1 {
+		t.Fatalf("expected 3 attempts and 1 panic, got %d / %d", calls.Load(), panics.Load())
+	}
+}
+
+// TestBatcher_Concurrency 验证同时运行的 flush 数不超过上限，Close 超时返回 ctx 错误。
+func TestBatcher_Concurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	flush := func(ctx context.Context, batch []int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+	b := NewBatcher(context.Background(), flush, WithBatchSize(1), WithFlushConcurrency(2))
+	for i := 0; i < 4; i++ {
+		_ = b.Add(context.Background(), i)
+	}
+	waitFor(t, func() bool { return running.Load() == 2 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	close(release)
+	if peak.Load() != 2 {
+		t.Fatalf("expected peak concurrency 2, got %d", peak.Load())
+	}
+}
+
+// TestBatcher_CloseWhileAddBlocked 验证 Add 因缓冲区满而阻塞时，Close 不会死锁：
+// 阻塞的 Add 返回 ErrBatcherClosed，Close 在自己的 ctx 到期时返回。
+func TestBatcher_CloseWhileAddBlocked(t *testing.T) {
+	release := make(chan struct{})
+	b := NewBatcher(context.Background(), func(ctx context.Context, batch []int) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}, WithBatchSize(1), WithBatchBuffer(1))
+	defer close(release)
+
+	// 1 条在 flush 中，1 条等待并发额度，1 条在缓冲区，第 4 条阻塞
+	for i := 0; i < 3; i++ {
+		if err := b.Add(context.Background(), i); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	blocked := make(chan error, 1)
+	go func() { blocked <- b.Add(context.Background(), 3) }()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := b.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Close to give up with the ctx, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Close took %v", elapsed)
+	}
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, ErrBatcherClosed) {
+			t.Fatalf("expected the blocked Add to return ErrBatcherClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked Add should return once the Batcher is closed")
+	}
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/Cotary/go-lib/common/appctx"
 	"github.com/Cotary/go-lib/common/defined"
-	"github.com/Cotary/go-lib/notify"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
@@ -28,55 +27,42 @@ func SafeGo(ctx context.Context, F func(ctx context.Context)) {
 // error（concurrent map writes 等）会跳过 defer，由 crash.go 的
 // InitCrashReporter 落盘 + 下次启动补报负责。
 //
-// 这里区分 r 的类型：原生 error 用 WithStack 保留底层信息以兼容
-// e.GetErrMessage 的渲染；非 error 值用 Errorf 转字符串。最终用
-// WithMessage 把 debug.Stack() 拼上去，避免 recover 处吃掉 panic
-// 现场（pkg/errors 的 stack 只到 recover 这一帧，不能反推真实抛点）。
+// recover 到的 panic 连同 debug.Stack() 交给 ReportPanic：与 L3 fatal
+// 使用同一 CrashRecord 格式，按指纹去重后上报，同一个 bug 短时间内
+// 反复 panic 只告警一次并附带次数（pkg/errors 的 stack 只到 recover
+// 这一帧，不能反推真实抛点，所以必须在 defer 里取完整栈）。
 func SafeFunc(ctx context.Context, F func(ctx context.Context)) {
 	defer func() {
-		r := recover()
-		if r == nil {
-			return
-		}
-		var err error
-		if e, ok := r.(error); ok {
-			err = errors.WithStack(e)
-		} else {
-			err = errors.Errorf("panic: %v", r)
+		if r := recover(); r != nil {
+			ReportPanic(ctx, r, debug.Stack())
 		}
-		err = errors.WithMessage(err, "stack:\n"+string(debug.Stack()))
-		notify.SendErrMessage(ctx, err)
 	}()
 	F(ctx)
 }
 
-// Retry 自己维护重试sleep时间
-func Retry(ctx context.Context, F func(ctx context.Context) error, count ...int) error {
-	maxRetries := -1
-	if len(count) > 0 {
-		maxRetries = count[0]
-	}
-
-	retries := 0
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			err := F(ctx)
-			if err != nil {
-				retries++
-				if maxRetries >= 0 && retries > maxRetries {
-					notify.SendErrMessage(ctx, errors.WithMessage(err, "Retry Error: Exceeded max retries"))
-					return err
-				}
-				notify.SendErrMessage(ctx, errors.WithMessage(err, "Retry Error"))
-			} else {
-				return nil
-			}
+// SafeFuncErr 与 SafeFunc 相同地执行 F，但不告警：F 返回的错误原样返回，
+// panic 被转换为带现场栈的 error 返回，由调用方决定如何处理（见 Group）。
+func SafeFuncErr(ctx context.Context, F func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(r)
 		}
+	}()
+	return F(ctx)
+}
+
+// panicError 把 recover() 得到的值转换为 error 并附上 debug.Stack()，
+// 必须在 recover 所在的 defer 中调用，栈才是 panic 现场。
+// 原生 error 用 WithStack 保留底层信息以兼容 e.GetErrMessage 的渲染；
+// 非 error 值用 Errorf 转字符串。
+func panicError(r any) error {
+	var err error
+	if e, ok := r.(error); ok {
+		err = errors.WithStack(e)
+	} else {
+		err = errors.Errorf("panic: %v", r)
 	}
+	return errors.WithMessage(err, "stack:\n"+string(debug.Stack()))
 }
 
 func NewContext(contextType string) context.Context {
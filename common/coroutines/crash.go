@@ -47,27 +47,41 @@ const uploadedSuffix = ".uploaded"
 
 // ===== 类型 =====
 
-// CrashRecord 表示一次历史崩溃的解析结果。
+// CrashRecord 表示一次崩溃：L3 fatal 由 dump 文件解析得到，L2 panic 由
+// ReportPanic 在 recover 现场构造，两者共用同一格式与上报通道。
 //
-// File 为绝对路径；Pid / StartedAt 由文件名拆解得到，文件名格式为
+// File 为绝对路径（panic 时为空）；Pid / StartedAt 由文件名拆解得到，文件名格式为
 // "<pid>-<yyyymmdd_HHMMSS>.crash"，解析失败时这两个字段保持零值。
-// Reason 是从 dump 中提取的首行 "fatal error: xxx"，便于告警标题展示；
-// Raw 为原始 dump 的字节内容，可能因 maxFileBytes 而被截断，此时
-// Truncated 为 true。完整内容仍在 File 中，可供人工排查。
+// Reason 是从 dump 中提取的首行 "fatal error: xxx"（panic 时为 "panic: xxx"），
+// 便于告警标题展示；Raw 为原始 dump 的字节内容（panic 时为现场栈），可能因
+// maxFileBytes 而被截断，此时 Truncated 为 true。完整内容仍在 File 中，可供人工排查。
+//
+// Fingerprint 标识"同一个崩溃"（见 Fingerprint 函数）；Count 为去重窗口合并后
+// 该指纹在本进程内累计出现的次数，FirstSeen / LastSeen 为首次与最近一次出现时间。
 type CrashRecord struct {
+	Kind      string // CrashKindFatal / CrashKindPanic
 	File      string
 	Pid       int
 	StartedAt time.Time
 	Reason    string
 	Raw       []byte
 	Truncated bool
+
+	Fingerprint string
+	Count       int
+	FirstSeen   time.Time
+	LastSeen    time.Time
 }
 
 // Render 把 CrashRecord 渲染成给人看的多行文本，
 // 用作默认 uploader 注入到 notify 的 error 消息体。
 func (r *CrashRecord) Render() string {
 	var b strings.Builder
-	b.WriteString("Process Crashed\n")
+	if r.Kind == CrashKindPanic {
+		b.WriteString("Panic Recovered\n")
+	} else {
+		b.WriteString("Process Crashed\n")
+	}
 	if r.Pid > 0 {
 		fmt.Fprintf(&b, "Pid: %d\n", r.Pid)
 	}
@@ -77,11 +91,24 @@ func (r *CrashRecord) Render() string {
 	if r.Reason != "" {
 		fmt.Fprintf(&b, "Reason: %s\n", r.Reason)
 	}
-	fmt.Fprintf(&b, "DumpFile: %s\n", r.File)
+	if r.Fingerprint != "" {
+		fmt.Fprintf(&b, "Fingerprint: %s\n", r.Fingerprint)
+	}
+	if r.Count > 1 {
+		fmt.Fprintf(&b, "Occurrences: %d (first %s, last %s)\n", r.Count,
+			r.FirstSeen.Format(time.RFC3339), r.LastSeen.Format(time.RFC3339))
+	}
+	if r.File != "" {
+		fmt.Fprintf(&b, "DumpFile: %s\n", r.File)
+	}
 	if r.Truncated {
 		fmt.Fprintf(&b, "Truncated: true (raw size > maxFileBytes)\n")
 	}
-	b.WriteString("---- raw dump ----\n")
+	if r.Kind == CrashKindPanic {
+		b.WriteString("---- stack ----\n")
+	} else {
+		b.WriteString("---- raw dump ----\n")
+	}
 	b.Write(r.Raw)
 	if len(r.Raw) > 0 && r.Raw[len(r.Raw)-1] != '\n' {
 		b.WriteByte('\n')
@@ -101,6 +128,8 @@ type crashConfig struct {
 	keepUploaded time.Duration
 	title        string
 	uploader     func(context.Context, *CrashRecord) error
+	dedupWindow  time.Duration
+	historySize  int
 }
 
 // CrashOption 配置 InitCrashReporter 行为；
@@ -170,6 +199,26 @@ func WithCrashUploader(fn func(context.Context, *CrashRecord) error) CrashOption
 	}
 }
 
+// WithDedupWindow 设置崩溃去重窗口：同一指纹在窗口内只上报第一次，
+// 其余合并计数，窗口结束时补发一条带总次数的汇总。默认 10 分钟。
+// 对 L2 panic 与 L3 fatal 均生效。
+func WithDedupWindow(d time.Duration) CrashOption {
+	return func(c *crashConfig) {
+		if d > 0 {
+			c.dedupWindow = d
+		}
+	}
+}
+
+// WithHistorySize 设置 RecentCrashes 保留的不同指纹数，超出时淘汰最久未出现的。默认 100。
+func WithHistorySize(n int) CrashOption {
+	return func(c *crashConfig) {
+		if n > 0 {
+			c.historySize = n
+		}
+	}
+}
+
 // ===== 内部状态 =====
 //
 // crashMu 保护以下三个变量：crashCfg 表示是否已初始化（nil = 未初始化），
@@ -221,6 +270,7 @@ func InitCrashReporter(opts ...CrashOption) error {
 	}
 
 	debug.SetTraceback(cfg.traceback)
+	crashes.configure(cfg.dedupWindow, cfg.historySize)
 
 	crashCfg = cfg
 	crashFile = f
@@ -290,11 +340,22 @@ func ReportPendingCrashes(ctx context.Context) error {
 			_ = os.Rename(path, path+uploadedSuffix)
 			continue
 		}
-		if uerr := uploader(ctx, rec); uerr != nil {
-			if firstErr == nil {
-				firstErr = uerr
+		rec.Kind = CrashKindFatal
+		rec.Fingerprint = Fingerprint(rec.Reason, rec.Raw)
+		rec.LastSeen = rec.StartedAt
+		if rec.LastSeen.IsZero() {
+			rec.LastSeen = time.Now()
+		}
+		// 同一次扫描里的重复崩溃（如崩溃重启循环留下的多份 dump）只上报一次，
+		// 其余直接归档，由去重窗口结束时的汇总带出总次数
+		if out := crashes.observe(rec); out != nil {
+			if uerr := uploader(ctx, out); uerr != nil {
+				crashes.unreported(rec.Fingerprint)
+				if firstErr == nil {
+					firstErr = uerr
+				}
+				continue
 			}
-			continue
 		}
 		if rerr := os.Rename(path, path+uploadedSuffix); rerr != nil && firstErr == nil {
 			firstErr = rerr
@@ -0,0 +1,256 @@
+package coroutines
+
+// 崩溃记录的指纹去重与本地历史，L2 panic 与 L3 fatal 共用。
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cotary/go-lib/log"
+)
+
+// CrashRecord.Kind 的取值。
+const (
+	CrashKindFatal = "fatal" // L3：runtime fatal error，下次启动时从 dump 文件补报
+	CrashKindPanic = "panic" // L2：被 recover 的 panic
+)
+
+const (
+	defaultDedupWindow = 10 * time.Minute
+	defaultHistorySize = 100
+	// fingerprintFrames 是参与指纹计算的栈帧数。
+	fingerprintFrames = 5
+)
+
+// crashHistory 按指纹聚合崩溃记录。
+type crashHistory struct {
+	mu      sync.Mutex
+	window  time.Duration
+	size    int
+	entries map[string]*crashEntry
+}
+
+type crashEntry struct {
+	rec        CrashRecord
+	reportedAt time.Time   // 上次上报时间，零值表示尚未成功上报
+	pending    int         // 上次上报之后被合并的次数
+	timer      *time.Timer // 窗口结束时补发汇总
+}
+
+var crashes = &crashHistory{
+	window:  defaultDedupWindow,
+	size:    defaultHistorySize,
+	entries: make(map[string]*crashEntry),
+}
+
+func (h *crashHistory) configure(window time.Duration, size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if window > 0 {
+		h.window = window
+	}
+	if size > 0 {
+		h.size = size
+	}
+}
+
+// observe 记录一次崩溃，返回需要立即上报的记录（已填好 Count 等字段）；
+// 同一指纹仍在去重窗口内时返回 nil，由窗口结束时的 flush 补发。
+func (h *crashHistory) observe(rec *CrashRecord) *CrashRecord {
+	now := rec.LastSeen
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e, ok := h.entries[rec.Fingerprint]
+	if !ok {
+		rec.Count = 1
+		rec.FirstSeen = now
+		h.entries[rec.Fingerprint] = &crashEntry{rec: *rec, reportedAt: now}
+		h.evict()
+		return rec
+	}
+
+	e.rec.Count++
+	e.rec.LastSeen = now
+	e.rec.Raw, e.rec.Truncated, e.rec.File, e.rec.Pid = rec.Raw, rec.Truncated, rec.File, rec.Pid
+	if !e.reportedAt.IsZero() && now.Sub(e.reportedAt) < h.window {
+		e.pending++
+		if e.timer == nil {
+			fp := rec.Fingerprint
+			e.timer = time.AfterFunc(e.reportedAt.Add(h.window).Sub(now), func() { h.flush(fp) })
+		}
+		return nil
+	}
+	e.reportedAt = now
+	e.pending = 0
+	out := e.rec
+	return &out
+}
+
+// unreported 在上报失败时调用，让下一次同指纹的崩溃重新上报。
+func (h *crashHistory) unreported(fingerprint string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.entries[fingerprint]; ok {
+		e.reportedAt = time.Time{}
+	}
+}
+
+// flush 在去重窗口结束时补发被合并的次数。
+func (h *crashHistory) flush(fingerprint string) {
+	h.mu.Lock()
+	e, ok := h.entries[fingerprint]
+	if !ok || e.pending == 0 {
+		if ok {
+			e.timer = nil
+		}
+		h.mu.Unlock()
+		return
+	}
+	e.timer = nil
+	e.pending = 0
+	e.reportedAt = time.Now()
+	rec := e.rec
+	h.mu.Unlock()
+
+	uploadCrash(NewContext("crash-report"), &rec)
+}
+
+// evict 超出容量时删除最久未出现的记录（调用方需持有 h.mu）。
+func (h *crashHistory) evict() {
+	for len(h.entries) > h.size {
+		var oldest string
+		for fp, e := range h.entries {
+			if oldest == "" || e.rec.LastSeen.Before(h.entries[oldest].rec.LastSeen) {
+				oldest = fp
+			}
+		}
+		if e := h.entries[oldest]; e.timer != nil {
+			e.timer.Stop()
+		}
+		delete(h.entries, oldest)
+	}
+}
+
+// RecentCrashes 返回本进程记录的最近崩溃（每个指纹一条，含累计次数），
+// 按最后出现时间从新到旧排序。fatal 记录来自启动时 ReportPendingCrashes 补报的上次崩溃。
+func RecentCrashes() []CrashRecord {
+	crashes.mu.Lock()
+	out := make([]CrashRecord, 0, len(crashes.entries))
+	for _, e := range crashes.entries {
+		out = append(out, e.rec)
+	}
+	crashes.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// ReportPanic 把 recover 到的 panic 值 r 及其现场栈（debug.Stack()）记录为 CrashRecord，
+// 去重后通过 crash uploader（默认 notify）上报。SafeFunc 与 gin RecoveryHandler 共用此入口，
+// 业务自行 recover 时也可调用。
+func ReportPanic(ctx context.Context, r any, stack []byte) {
+	reason := fmt.Sprintf("panic: %v", r)
+	if err, ok := r.(error); ok {
+		reason = "panic: " + err.Error()
+	}
+	now := time.Now()
+	rec := &CrashRecord{
+		Kind:        CrashKindPanic,
+		Pid:         os.Getpid(),
+		Reason:      reason,
+		Raw:         stack,
+		Fingerprint: Fingerprint(reason, stack),
+		LastSeen:    now,
+	}
+	if out := crashes.observe(rec); out != nil {
+		uploadCrash(ctx, out)
+	}
+}
+
+// uploadCrash 用当前配置的 uploader 上报 rec，失败时写日志并允许下次重新上报。
+func uploadCrash(ctx context.Context, rec *CrashRecord) {
+	if err := currentUploader()(ctx, rec); err != nil {
+		crashes.unreported(rec.Fingerprint)
+		log.WithContext(ctx).WithField("action", "uploadCrash").Error(err.Error())
+	}
+}
+
+func currentUploader() func(context.Context, *CrashRecord) error {
+	crashMu.Lock()
+	defer crashMu.Unlock()
+	if crashCfg != nil && crashCfg.uploader != nil {
+		return crashCfg.uploader
+	}
+	return defaultUploader
+}
+
+var (
+	hexPattern   = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	digitPattern = regexp.MustCompile(`[0-9]+`)
+)
+
+// Fingerprint 计算崩溃指纹：归一化的 reason 加上 panic 现场最顶上的若干业务栈帧。
+// stack 为 debug.Stack() 或 runtime dump 的格式；同一代码位置的同一类错误得到相同指纹，
+// 与行号、参数、地址、具体数值无关。
+func Fingerprint(reason string, stack []byte) string {
+	normalized := digitPattern.ReplaceAllString(hexPattern.ReplaceAllString(reason, "0x?"), "N")
+	h := sha1.New()
+	h.Write([]byte(normalized))
+	for _, frame := range topFrames(stack, fingerprintFrames) {
+		h.Write([]byte{'\n'})
+		h.Write([]byte(frame))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// topFrames 取第一个 goroutine 中 panic 现场（最后一个 "panic(" 帧之后）的前 n 个函数名，
+// 跳过 runtime 内部帧；没有 "panic(" 帧（如 fatal error）时从栈顶开始。
+// 第一个 "goroutine " 行之前的内容（如 fatal error 的 "fatal error: ..." 头部）不是栈帧，忽略。
+func topFrames(stack []byte, n int) []string {
+	var funcs []string
+	started := false
+	for _, line := range strings.Split(string(stack), "\n") {
+		if !started {
+			started = strings.HasPrefix(line, "goroutine ")
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			if len(funcs) > 0 {
+				break // 只看第一个 goroutine
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "goroutine ") || strings.HasPrefix(line, "created by ") {
+			continue
+		}
+		fn := line
+		if i := strings.LastIndexByte(fn, '('); i > 0 {
+			fn = fn[:i]
+		}
+		if fn == "panic" {
+			funcs = funcs[:0] // 之前的帧属于 recover 链路
+			continue
+		}
+		funcs = append(funcs, fn)
+	}
+
+	frames := make([]string, 0, n)
+	for _, fn := range funcs {
+		if strings.HasPrefix(fn, "runtime.") || strings.HasPrefix(fn, "runtime/debug.") {
+			continue
+		}
+		frames = append(frames, fn)
+		if len(frames) == n {
+			break
+		}
+	}
+	return frames
+}
@@ -0,0 +1,153 @@
+package coroutines
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useTestUploader 把 uploader 替换为记录器，返回已上报的记录。
+func useTestUploader(t *testing.T) func() []CrashRecord {
+	t.Helper()
+	resetCrashStateForTest(t)
+	t.Cleanup(func() { resetCrashStateForTest(t) })
+
+	var (
+		mu  sync.Mutex
+		got []CrashRecord
+	)
+	crashMu.Lock()
+	crashCfg = &crashConfig{uploader: func(ctx context.Context, rec *CrashRecord) error {
+		mu.Lock()
+		got = append(got, *rec)
+		mu.Unlock()
+		return nil
+	}}
+	crashMu.Unlock()
+	return func() []CrashRecord {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]CrashRecord(nil), got...)
+	}
+}
+
+func panicAt(i int) {
+	var s []int
+	_ = s[i]
+}
+
+func otherPanic() {
+	panic("other")
+}
+
+// capture 执行 fn 并返回 recover 到的值与现场栈。
+func capture(fn func()) (r any, stack []byte) {
+	defer func() {
+		r = recover()
+		stack = debug.Stack()
+	}()
+	fn()
+	return nil, nil
+}
+
+// TestFingerprint 验证同一位置、不同数值的 panic 指纹相同，不同位置的指纹不同。
+func TestFingerprint(t *testing.T) {
+	var fps []string
+	var s1 []byte
+	for _, i := range []int{3, 7} {
+		r, s := capture(func() { panicAt(i) })
+		fps = append(fps, Fingerprint(fmt.Sprint(r), s))
+		s1 = s
+	}
+	r3, s3 := capture(otherPanic)
+
+	f1, f2 := fps[0], fps[1]
+	f3 := Fingerprint(fmt.Sprint(r3), s3)
+	if f1 != f2 {
+		t.Fatalf("same site should share a fingerprint: %s vs %s", f1, f2)
+	}
+	if f1 == f3 {
+		t.Fatal("different sites should not share a fingerprint")
+	}
+	if frames := topFrames(s1, 1); len(frames) != 1 || frames[0] != "github.com/Cotary/go-lib/common/coroutines.panicAt" {
+		t.Fatalf("unexpected top frame: %v", frames)
+	}
+}
+
+// TestReportPanic_Dedup 验证窗口内重复 panic 只上报一次，窗口结束补发带次数的汇总。
+func TestReportPanic_Dedup(t *testing.T) {
+	uploaded := useTestUploader(t)
+	crashes.configure(50*time.Millisecond, 0)
+
+	for i := 0; i < 5; i++ {
+		SafeFunc(context.Background(), func(ctx context.Context) { panicAt(i + 10) })
+	}
+	SafeFunc(context.Background(), func(ctx context.Context) { otherPanic() })
+
+	if got := uploaded(); len(got) != 2 || got[0].Kind != CrashKindPanic || got[0].Count != 1 {
+		t.Fatalf("expected one report per fingerprint, got %+v", got)
+	}
+	waitFor(t, func() bool { return len(uploaded()) == 3 })
+	if summary := uploaded()[2]; summary.Count != 5 || summary.FirstSeen.IsZero() {
+		t.Fatalf("expected a summary with 5 occurrences, got %+v", summary)
+	}
+
+	recent := RecentCrashes()
+	if len(recent) != 2 || recent[0].Reason != "panic: other" {
+		t.Fatalf("unexpected history: %+v", recent)
+	}
+}
+
+// TestReportPendingCrashes_Dedup 验证同一次扫描中相同位置的 fatal dump 只上报一次，
+// 不同位置的 fatal dump 得到不同的指纹、分别上报。
+func TestReportPendingCrashes_Dedup(t *testing.T) {
+	uploaded := useTestUploader(t)
+	dir := t.TempDir()
+	crashMu.Lock()
+	crashCfg.dir = dir
+	crashCfg.maxFileBytes = 1024
+	crashMu.Unlock()
+
+	dump := func(fn string) string {
+		return "fatal error: concurrent map writes\n\ngoroutine 7 [running]:\n" + fn + "()\n\t/app/main.go:12 +0x1d\n"
+	}
+	files := map[string]string{
+		"101-20240101_010101.crash": dump("main.worker"),
+		"102-20240101_010201.crash": dump("main.worker"),
+		"103-20240101_010301.crash": dump("main.other"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write dump %s: %v", name, err)
+		}
+	}
+
+	if err := ReportPendingCrashes(context.Background()); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	got := uploaded()
+	if len(got) != 2 || got[0].Kind != CrashKindFatal || got[1].Kind != CrashKindFatal {
+		t.Fatalf("expected one fatal report per stack, got %+v", got)
+	}
+	if got[0].Fingerprint == got[1].Fingerprint {
+		t.Fatalf("different stacks should not share a fingerprint: %s", got[0].Fingerprint)
+	}
+	recent := RecentCrashes()
+	counts := map[int]int{}
+	for _, rec := range recent {
+		counts[rec.Count]++
+	}
+	if len(recent) != 2 || counts[2] != 1 || counts[1] != 1 {
+		t.Fatalf("expected history entries with 2 and 1 occurrences, got %+v", recent)
+	}
+	for name := range files {
+		if _, err := os.Stat(filepath.Join(dir, name+uploadedSuffix)); err != nil {
+			t.Fatalf("%s should be archived: %v", name, err)
+		}
+	}
+}
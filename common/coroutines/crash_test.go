@@ -29,6 +29,17 @@ func resetCrashStateForTest(t *testing.T) {
 	crashFile = nil
 	crashFilePid = 0
 	crashMu.Unlock()
+
+	crashes.mu.Lock()
+	for _, e := range crashes.entries {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+	crashes.entries = make(map[string]*crashEntry)
+	crashes.window = defaultDedupWindow
+	crashes.size = defaultHistorySize
+	crashes.mu.Unlock()
 }
 
 // TestInitCrashReporter_Idempotent 验证重复调用不会出错也不会覆盖现有 fd。
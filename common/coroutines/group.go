@@ -0,0 +1,135 @@
+package coroutines
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// 并发任务组：与 ConcurrentProcessor 不同，任务的错误与 panic 会汇总到 Wait。
+
+type groupConfig struct {
+	limit      int // <= 0 表示不限
+	collectAll bool
+}
+
+// GroupOption 配置 Group 的行为。
+type GroupOption func(*groupConfig)
+
+// WithGroupLimit 限制同时运行的任务数，n <= 0 表示不限（默认）。
+// 达到上限时 Go 会阻塞，直到有任务结束。
+func WithGroupLimit(n int) GroupOption {
+	return func(c *groupConfig) { c.limit = n }
+}
+
+// WithCollectAll 关闭 fail-fast：任务出错时不取消组内 ctx，Wait 等待全部任务结束后
+// 以 errors.Join 返回所有错误。
+func WithCollectAll() GroupOption {
+	return func(c *groupConfig) { c.collectAll = true }
+}
+
+// Group 是 panic 安全、可限制并发的任务组，语义参照 errgroup.Group。
+// 默认 fail-fast：第一个错误取消组内 ctx，Wait 返回该错误；WithCollectAll 改为收集全部错误。
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	cfg    groupConfig
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewGroup 创建任务组并返回派生的 ctx：fail-fast 模式下第一个任务出错时该 ctx 被取消
+// （context.Cause 为该错误），Wait 返回后也会被取消。
+func NewGroup(ctx context.Context, opts ...GroupOption) (*Group, context.Context) {
+	var cfg groupConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := &Group{ctx: ctx, cancel: cancel, cfg: cfg}
+	if cfg.limit > 0 {
+		g.sem = make(chan struct{}, cfg.limit)
+	}
+	return g, ctx
+}
+
+// Go 在新协程中执行 f，f 收到的是组内 ctx。f 经 SafeFuncErr 执行，panic 转为带栈的 error。
+// 设置了 WithGroupLimit 且已达上限时，Go 阻塞到有任务结束。
+func (g *Group) Go(f func(ctx context.Context) error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			if g.sem != nil {
+				<-g.sem
+			}
+			g.wg.Done()
+		}()
+		if err := SafeFuncErr(g.ctx, f); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	g.errs = append(g.errs, err)
+	g.mu.Unlock()
+	if !g.cfg.collectAll {
+		g.cancel(err)
+	}
+}
+
+// Wait 等待所有任务结束。fail-fast 模式返回第一个错误，collect-all 模式返回全部错误的
+// errors.Join，没有错误时返回 nil。
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(context.Canceled)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	if g.cfg.collectAll {
+		return errors.Join(g.errs...)
+	}
+	return g.errs[0]
+}
+
+// Result 是 Map 中单个元素的处理结果。
+type Result[R any] struct {
+	Value R
+	Err   error
+}
+
+// Map 以 Group 并发处理 items，返回与 items 一一对应的结果以及 Group.Wait 的错误。
+// fail-fast 模式下，出错后尚未开始的元素不再执行，其 Err 为取消原因。
+func Map[T, R any](ctx context.Context, items []T, fn func(ctx context.Context, item T) (R, error), opts ...GroupOption) ([]Result[R], error) {
+	results := make([]Result[R], len(items))
+	g, gctx := NewGroup(ctx, opts...)
+	for i, item := range items {
+		if gctx.Err() != nil {
+			results[i].Err = context.Cause(gctx)
+			continue
+		}
+		g.Go(func(ctx context.Context) error {
+			if ctx.Err() != nil {
+				results[i].Err = context.Cause(ctx)
+				return nil
+			}
+			err := SafeFuncErr(ctx, func(ctx context.Context) error {
+				v, err := fn(ctx, item)
+				results[i].Value = v
+				return err
+			})
+			results[i].Err = err
+			return err
+		})
+	}
+	return results, g.Wait()
+}
@@ -0,0 +1,119 @@
+package coroutines
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestGroup_PanicToError 验证任务 panic 被转换为带栈的 error 返回。
+func TestGroup_PanicToError(t *testing.T) {
+	g, _ := NewGroup(context.Background())
+	g.Go(func(ctx context.Context) error {
+		panic("boom")
+	})
+	err := g.Wait()
+	if err == nil || !strings.Contains(err.Error(), "panic: boom") || !strings.Contains(err.Error(), "stack:") {
+		t.Fatalf("expected panic error with stack, got %v", err)
+	}
+}
+
+// TestGroup_FailFast 验证第一个错误会取消组内 ctx。
+func TestGroup_FailFast(t *testing.T) {
+	boom := errors.New("boom")
+	g, ctx := NewGroup(context.Background())
+	g.Go(func(ctx context.Context) error { return boom })
+	g.Go(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return errors.New("not canceled")
+		}
+	})
+	if err := g.Wait(); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if !errors.Is(context.Cause(ctx), boom) {
+		t.Fatalf("expected cause boom, got %v", context.Cause(ctx))
+	}
+}
+
+// TestGroup_CollectAll 验证 collect-all 模式返回全部错误且不取消其它任务。
+func TestGroup_CollectAll(t *testing.T) {
+	e1, e2 := errors.New("e1"), errors.New("e2")
+	var done atomic.Int32
+	g, _ := NewGroup(context.Background(), WithCollectAll())
+	g.Go(func(ctx context.Context) error { return e1 })
+	g.Go(func(ctx context.Context) error { return e2 })
+	g.Go(func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		if ctx.Err() == nil {
+			done.Add(1)
+		}
+		return nil
+	})
+	err := g.Wait()
+	if !errors.Is(err, e1) || !errors.Is(err, e2) {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+	if done.Load() != 1 {
+		t.Fatal("collect-all should not cancel other tasks")
+	}
+}
+
+// TestGroup_Limit 验证同时运行的任务数不超过上限。
+func TestGroup_Limit(t *testing.T) {
+	var running, peak atomic.Int32
+	g, _ := NewGroup(context.Background(), WithGroupLimit(3))
+	for i := 0; i < 20; i++ {
+		g.Go(func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if peak.Load() > 3 {
+		t.Fatalf("peak concurrency %d exceeds limit 3", peak.Load())
+	}
+}
+
+// TestMap_OrderedResults 验证 Map 按输入顺序返回结果与逐项错误。
+func TestMap_OrderedResults(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	results, err := Map(context.Background(), items, func(ctx context.Context, n int) (int, error) {
+		time.Sleep(time.Duration(5-n) * time.Millisecond)
+		if n == 3 {
+			return 0, errors.New("three")
+		}
+		return n * n, nil
+	}, WithGroupLimit(2), WithCollectAll())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for i, r := range results {
+		n := items[i]
+		if n == 3 {
+			if r.Err == nil {
+				t.Fatal("expected item 3 to fail")
+			}
+			continue
+		}
+		if r.Err != nil || r.Value != n*n {
+			t.Fatalf("result %d = %+v, want %d", i, r, n*n)
+		}
+	}
+}
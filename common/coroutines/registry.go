@@ -0,0 +1,138 @@
+package coroutines
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Cotary/go-lib/common/defined"
+)
+
+// 具名协程登记表，用于按名字定位泄漏与长时间运行的协程。
+
+// GoroutineInfo 是一个存活的具名协程。
+type GoroutineInfo struct {
+	ID        uint64        `json:"id"`
+	RequestID string        `json:"request_id,omitempty"` // 启动时 ctx 中的请求 ID
+	StartedAt time.Time     `json:"started_at"`
+	Running   time.Duration `json:"running"` // 截至快照时的运行时长
+}
+
+// GoroutineGroup 是同名协程的统计。
+type GoroutineGroup struct {
+	Name       string          `json:"name"`
+	Live       int             `json:"live"`     // 当前存活数
+	Started    uint64          `json:"started"`  // 累计启动数
+	Panicked   uint64          `json:"panicked"` // 累计以 panic 结束的次数
+	Goroutines []GoroutineInfo `json:"goroutines"`
+}
+
+// GoroutineReport 是 GoroutineSnapshot 的结果。
+type GoroutineReport struct {
+	Total  int              `json:"total"` // runtime.NumGoroutine()，包含未登记的协程
+	Named  int              `json:"named"` // 登记表中存活的协程总数
+	Groups []GoroutineGroup `json:"groups"`
+}
+
+type namedGroup struct {
+	started  uint64
+	panicked uint64
+	live     map[uint64]*GoroutineInfo
+}
+
+var registry = struct {
+	mu     sync.Mutex
+	nextID uint64
+	groups map[string]*namedGroup
+}{groups: make(map[string]*namedGroup)}
+
+// SafeGoNamed 与 SafeGo 相同，但以 name 登记该协程直到其退出；SafeGo 不做登记，开销不变。
+// name 应标识代码位置而非具体数据（如 "order-consumer"，而不是带订单号），
+// 否则登记表会按数据无限增长。
+func SafeGoNamed(ctx context.Context, name string, F func(ctx context.Context)) {
+	id := register(ctx, name)
+	go func() {
+		finished := false
+		defer func() { unregister(name, id, !finished) }()
+		SafeFunc(ctx, func(ctx context.Context) {
+			F(ctx)
+			finished = true
+		})
+	}()
+}
+
+func register(ctx context.Context, name string) uint64 {
+	info := &GoroutineInfo{StartedAt: time.Now()}
+	if v := ctx.Value(defined.RequestID); v != nil {
+		info.RequestID = fmt.Sprint(v)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.nextID++
+	info.ID = registry.nextID
+	g, ok := registry.groups[name]
+	if !ok {
+		g = &namedGroup{live: make(map[uint64]*GoroutineInfo)}
+		registry.groups[name] = g
+	}
+	g.started++
+	g.live[info.ID] = info
+	return info.ID
+}
+
+func unregister(name string, id uint64, panicked bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	g := registry.groups[name]
+	delete(g.live, id)
+	if panicked {
+		g.panicked++
+	}
+}
+
+// LiveGoroutines 返回名为 name 的协程当前存活数。
+func LiveGoroutines(name string) int {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if g, ok := registry.groups[name]; ok {
+		return len(g.live)
+	}
+	return 0
+}
+
+// GoroutineSnapshot 返回登记表的快照：按名字排序，每组内按启动时间从早到晚排序。
+// 只包含 SafeGoNamed 启动的协程。
+// minRunning > 0 时每组只列出运行时长不短于它的协程（Live 等统计不受影响）。
+func GoroutineSnapshot(minRunning time.Duration) GoroutineReport {
+	now := time.Now()
+	report := GoroutineReport{Total: runtime.NumGoroutine()}
+
+	registry.mu.Lock()
+	for name, g := range registry.groups {
+		group := GoroutineGroup{Name: name, Live: len(g.live), Started: g.started, Panicked: g.panicked}
+		for _, info := range g.live {
+			running := now.Sub(info.StartedAt)
+			if running < minRunning {
+				continue
+			}
+			item := *info
+			item.Running = running
+			group.Goroutines = append(group.Goroutines, item)
+		}
+		report.Named += group.Live
+		report.Groups = append(report.Groups, group)
+	}
+	registry.mu.Unlock()
+
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].Name < report.Groups[j].Name })
+	for _, group := range report.Groups {
+		sort.Slice(group.Goroutines, func(i, j int) bool {
+			return group.Goroutines[i].StartedAt.Before(group.Goroutines[j].StartedAt)
+		})
+	}
+	return report
+}
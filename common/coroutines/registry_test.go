@@ -0,0 +1,67 @@
+package coroutines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Cotary/go-lib/common/defined"
+)
+
+// TestSafeGoNamed_Registry 验证具名协程的登记、快照与注销。
+func TestSafeGoNamed_Registry(t *testing.T) {
+	const name = "test-registry-worker"
+	ctx := context.WithValue(context.Background(), defined.RequestID, "req-1")
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		SafeGoNamed(ctx, name, func(ctx context.Context) { <-release })
+	}
+	if n := LiveGoroutines(name); n != 3 {
+		t.Fatalf("live = %d, want 3", n)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	var group *GoroutineGroup
+	report := GoroutineSnapshot(10 * time.Millisecond)
+	for i := range report.Groups {
+		if report.Groups[i].Name == name {
+			group = &report.Groups[i]
+		}
+	}
+	if group == nil || group.Live != 3 || len(group.Goroutines) != 3 {
+		t.Fatalf("unexpected group: %+v", group)
+	}
+	if g := group.Goroutines[0]; g.RequestID != "req-1" || g.Running < 10*time.Millisecond {
+		t.Fatalf("unexpected goroutine info: %+v", g)
+	}
+	if report.Total < report.Named {
+		t.Fatalf("total %d < named %d", report.Total, report.Named)
+	}
+
+	close(release)
+	waitFor(t, func() bool { return LiveGoroutines(name) == 0 })
+}
+
+// TestSafeGoNamed_Panic 验证 panic 结束的协程会被注销并计数。
+func TestSafeGoNamed_Panic(t *testing.T) {
+	name := "test-registry-panic-" + time.Now().Format("150405.000000000")
+	SafeGoNamed(context.Background(), name, func(ctx context.Context) { panic("boom") })
+	waitFor(t, func() bool { return LiveGoroutines(name) == 0 })
+
+	for _, g := range GoroutineSnapshot(0).Groups {
+		if g.Name == name && (g.Started != 1 || g.Panicked != 1) {
+			t.Fatalf("unexpected group: %+v", g)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
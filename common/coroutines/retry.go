@@ -0,0 +1,189 @@
+package coroutines
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	e "github.com/Cotary/go-lib/err"
+	"github.com/Cotary/go-lib/notify"
+	"github.com/pkg/errors"
+)
+
+// 重试策略：指数退避加抖动，按次数或总耗时设上限，只在重试耗尽时发一条汇总告警。
+
+// retryConfig 保存 Retry 的策略，保持 Functional Options 风格。
+type retryConfig struct {
+	maxRetries int // 首次失败后最多再试几次，< 0 表示不限
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+	maxElapsed time.Duration // 0 表示不限
+	retryable  func(error) bool
+	alert      func(context.Context, error)
+}
+
+func defaultRetryConfig() *retryConfig {
+	return &retryConfig{
+		maxRetries: -1,
+		initial:    100 * time.Millisecond,
+		max:        30 * time.Second,
+		multiplier: 2,
+		jitter:     0.2,
+		retryable:  RetryUnlessCode(e.ParamErrCode),
+		alert:      notify.SendErrMessage,
+	}
+}
+
+// RetryOption 配置 RetryWithOptions 的策略；非法参数会被忽略。
+type RetryOption func(*retryConfig)
+
+// WithMaxRetries 限制首次失败后的最大重试次数，n < 0 表示不限（默认）。
+func WithMaxRetries(n int) RetryOption {
+	return func(c *retryConfig) { c.maxRetries = n }
+}
+
+// WithBackoff 设置退避的初始间隔与上限，默认 100ms / 30s。
+func WithBackoff(initial, max time.Duration) RetryOption {
+	return func(c *retryConfig) {
+		if initial > 0 {
+			c.initial = initial
+		}
+		if max > 0 {
+			c.max = max
+		}
+	}
+}
+
+// WithMultiplier 设置每次退避间隔的放大倍数，默认 2；传 1 即固定间隔。
+func WithMultiplier(m float64) RetryOption {
+	return func(c *retryConfig) {
+		if m >= 1 {
+			c.multiplier = m
+		}
+	}
+}
+
+// WithJitter 设置抖动比例 f（0~1），实际间隔在 [d*(1-f), d*(1+f)] 内随机，默认 0.2。
+// 抖动用于错开多个实例的重试时间，避免同时打到依赖方。
+func WithJitter(f float64) RetryOption {
+	return func(c *retryConfig) {
+		if f >= 0 && f <= 1 {
+			c.jitter = f
+		}
+	}
+}
+
+// WithMaxElapsedTime 限制从第一次尝试开始的总耗时，下一次等待会超出时即放弃。默认不限。
+func WithMaxElapsedTime(d time.Duration) RetryOption {
+	return func(c *retryConfig) {
+		if d > 0 {
+			c.maxElapsed = d
+		}
+	}
+}
+
+// WithRetryIf 设置可重试判断，返回 false 的错误不再重试、直接返回且不告警。
+// 默认为 RetryUnlessCode(e.ParamErrCode)。
+func WithRetryIf(fn func(error) bool) RetryOption {
+	return func(c *retryConfig) {
+		if fn != nil {
+			c.retryable = fn
+		}
+	}
+}
+
+// WithRetryAlert 替换重试耗尽时的告警（默认 notify.SendErrMessage），传 nil 关闭告警。
+func WithRetryAlert(fn func(ctx context.Context, err error)) RetryOption {
+	return func(c *retryConfig) { c.alert = fn }
+}
+
+// RetryUnlessCode 返回一个可重试判断：错误链上的 CodeErr / HttpErr 的 code
+// 属于 codes 时不重试，context.Canceled 也不重试，其余错误均重试。
+func RetryUnlessCode(codes ...int) func(error) bool {
+	return func(err error) bool {
+		if errors.Is(err, context.Canceled) {
+			return false
+		}
+		var codeErr *e.CodeErr
+		var httpErr *e.HttpErr
+		code := 0
+		switch {
+		case errors.As(err, &httpErr):
+			code = httpErr.Code
+		case errors.As(err, &codeErr):
+			code = codeErr.Code
+		default:
+			return true
+		}
+		for _, c := range codes {
+			if c == code {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Retry 按默认策略重试 F 直到成功，count 为首次失败后的最大重试次数（不传表示不限）。
+// 见 RetryWithOptions。
+func Retry(ctx context.Context, F func(ctx context.Context) error, count ...int) error {
+	if len(count) > 0 {
+		return RetryWithOptions(ctx, F, WithMaxRetries(count[0]))
+	}
+	return RetryWithOptions(ctx, F)
+}
+
+// RetryWithOptions 按策略重试 F 直到成功：
+//   - F 返回不可重试的错误时立即原样返回；
+//   - 两次尝试之间按指数退避等待，等待期间 ctx 取消则返回 ctx.Err()；
+//   - 超过最大重试次数或最长总耗时时，发送一条汇总告警并返回最后一次的错误。
+func RetryWithOptions(ctx context.Context, F func(ctx context.Context) error, opts ...RetryOption) error {
+	cfg := defaultRetryConfig()
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	start := time.Now()
+	interval := cfg.initial
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := F(ctx)
+		if err == nil {
+			return nil
+		}
+		if !cfg.retryable(err) {
+			return err
+		}
+
+		delay := cfg.jittered(interval)
+		elapsed := time.Since(start)
+		if (cfg.maxRetries >= 0 && attempt > cfg.maxRetries) ||
+			(cfg.maxElapsed > 0 && elapsed+delay > cfg.maxElapsed) {
+			if cfg.alert != nil {
+				cfg.alert(ctx, errors.WithMessagef(err, "Retry Error: gave up after %d attempts in %s", attempt, elapsed.Round(time.Millisecond)))
+			}
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		interval = min(time.Duration(float64(interval)*cfg.multiplier), cfg.max)
+	}
+}
+
+// jittered 给 d 加上 ±jitter 比例的随机抖动，结果不超过 max。
+func (c *retryConfig) jittered(d time.Duration) time.Duration {
+	if c.jitter > 0 {
+		d = time.Duration(float64(d) * (1 + c.jitter*(2*rand.Float64()-1)))
+	}
+	return min(d, c.max)
+}
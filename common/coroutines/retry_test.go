@@ -0,0 +1,101 @@
+package coroutines
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	e "github.com/Cotary/go-lib/err"
+)
+
+// TestRetry_BackoffAndSingleAlert 验证重试间隔按指数增长，且耗尽时只告警一次。
+func TestRetry_BackoffAndSingleAlert(t *testing.T) {
+	var (
+		calls  []time.Time
+		alerts int
+	)
+	boom := errors.New("boom")
+	err := RetryWithOptions(context.Background(), func(ctx context.Context) error {
+		calls = append(calls, time.Now())
+		return boom
+	},
+		WithMaxRetries(3),
+		WithBackoff(10*time.Millisecond, time.Second),
+		WithJitter(0),
+		WithRetryAlert(func(ctx context.Context, err error) { alerts++ }),
+	)
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected last error, got %v", err)
+	}
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 attempts, got %d", len(calls))
+	}
+	if alerts != 1 {
+		t.Fatalf("expected exactly one alert, got %d", alerts)
+	}
+	// 10ms, 20ms, 40ms
+	for i, want := range []time.Duration{10, 20, 40} {
+		if gap := calls[i+1].Sub(calls[i]); gap < want*time.Millisecond {
+			t.Fatalf("gap %d = %s, want >= %dms", i, gap, want)
+		}
+	}
+}
+
+// TestRetry_NonRetryable 验证参数错误不重试也不告警。
+func TestRetry_NonRetryable(t *testing.T) {
+	calls, alerts := 0, 0
+	err := RetryWithOptions(context.Background(), func(ctx context.Context) error {
+		calls++
+		return e.NewHttpErr(e.ParamErr, errors.New("bad id"))
+	}, WithRetryAlert(func(ctx context.Context, err error) { alerts++ }))
+	if err == nil || calls != 1 || alerts != 0 {
+		t.Fatalf("err=%v calls=%d alerts=%d, want one silent attempt", err, calls, alerts)
+	}
+}
+
+// TestRetry_MaxElapsed 验证总耗时上限。
+func TestRetry_MaxElapsed(t *testing.T) {
+	alerts := 0
+	start := time.Now()
+	err := RetryWithOptions(context.Background(), func(ctx context.Context) error {
+		return errors.New("boom")
+	},
+		WithBackoff(10*time.Millisecond, 10*time.Millisecond),
+		WithMaxElapsedTime(50*time.Millisecond),
+		WithRetryAlert(func(ctx context.Context, err error) { alerts++ }),
+	)
+	if err == nil || alerts != 1 {
+		t.Fatalf("err=%v alerts=%d, want failure with one alert", err, alerts)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("retry ran for %s, want about 50ms", d)
+	}
+}
+
+// TestRetry_CtxCancel 验证等待期间取消 ctx 会立即返回。
+func TestRetry_CtxCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	err := RetryWithOptions(ctx, func(ctx context.Context) error {
+		return errors.New("boom")
+	}, WithBackoff(time.Hour, time.Hour), WithRetryAlert(nil))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+// TestRetry_Succeeds 验证成功后立即返回。
+func TestRetry_Succeeds(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("err=%v calls=%d, want success on the third attempt", err, calls)
+	}
+}
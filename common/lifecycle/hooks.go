@@ -0,0 +1,49 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	"github.com/Cotary/go-lib/notify"
+)
+
+// CloseFunc 把 Close() error 形式的关闭函数（GormDrive.Close、redis Client.Close、
+// Scheduler.Stop 等）适配为 OnStop，超时由 Manager 控制。
+func CloseFunc(fn func() error) func(ctx context.Context) error {
+	return func(context.Context) error { return fn() }
+}
+
+// StopFunc 把无返回值的关闭函数（rabbitMQ Connect.Close、ws Server.CloseAll 等）适配为 OnStop。
+func StopFunc(fn func()) func(ctx context.Context) error {
+	return func(context.Context) error {
+		fn()
+		return nil
+	}
+}
+
+// HTTPServer 返回管理 srv 的 Hook：OnStart 同步监听 srv.Addr（端口占用等错误直接
+// 导致启动失败）后在后台 Serve，Serve 异常退出时通过 notify 告警；OnStop 调用
+// srv.Shutdown 等待进行中的请求完成。
+func HTTPServer(srv *http.Server, priority int) Hook {
+	return Hook{
+		Name:     "http " + srv.Addr,
+		Priority: priority,
+		OnStart: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+				if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					notify.SendErrMessage(ctx, fmt.Errorf("lifecycle: http server %s: %w", srv.Addr, err))
+				}
+			})
+			return nil
+		},
+		OnStop: srv.Shutdown,
+	}
+}
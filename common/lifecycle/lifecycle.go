@@ -0,0 +1,247 @@
+// Package lifecycle 统一管理进程内组件的启动与优雅关闭。
+//
+// 各组件以 Hook 注册启动/停止函数，按 Priority 从小到大依次启动，
+// 收到 SIGINT / SIGTERM（或 ctx 取消、调用 Shutdown）后按相反顺序停止：
+// 先停止接流量的 HTTP / WebSocket 服务与定时任务，再关闭它们依赖的
+// MQ / Redis / 数据库，最后关闭告警 Sender。
+//
+// 使用方式：
+//
+//	lifecycle.Append(lifecycle.Hook{Name: "gorm", Priority: lifecycle.PriorityStorage, OnStop: lifecycle.CloseFunc(db.Close)})
+//	lifecycle.Append(lifecycle.HTTPServer(srv, lifecycle.PriorityServer))
+//	if err := lifecycle.Run(ctx); err != nil { ... }
+//
+// 停止失败（返回错误、超时或 panic）不会中断后续组件的关闭，
+// 每个失败都会记录日志并通过 notify 告警，Stop 最终以 errors.Join 返回全部错误。
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	"github.com/Cotary/go-lib/log"
+	"github.com/Cotary/go-lib/notify"
+)
+
+// 常用组件的推荐优先级：数值越小越先启动、越晚停止。
+const (
+	PriorityNotify    = -100 // 告警 Sender：最后关闭，保证其它组件的停止失败能发出去
+	PriorityStorage   = 0    // 数据库 / Redis / MongoDB
+	PriorityMessaging = 100  // RabbitMQ 等消息队列
+	PriorityWorker    = 200  // 定时任务、后台消费者
+	PriorityServer    = 300  // HTTP / WebSocket 服务：最先停止接流量
+)
+
+// ErrStopTimeout 表示组件未在超时时间内完成停止。
+var ErrStopTimeout = errors.New("lifecycle: stop timed out")
+
+// Hook 描述一个受管理的组件。OnStart / OnStop 均可为 nil。
+type Hook struct {
+	Name     string
+	Priority int                             // 越小越先启动、越晚停止；相同优先级按注册顺序
+	Timeout  time.Duration                   // OnStop 的超时时间，0 表示使用 WithStopTimeout 的默认值
+	OnStart  func(ctx context.Context) error // 不应阻塞，长期运行的服务需自行起协程
+	OnStop   func(ctx context.Context) error // ctx 在 Timeout 后取消
+}
+
+type config struct {
+	stopTimeout time.Duration
+	signals     []os.Signal
+}
+
+// Option 配置 Manager。
+type Option func(*config)
+
+// WithStopTimeout 设置 Hook 未指定 Timeout 时的停止超时，默认 10s。
+func WithStopTimeout(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.stopTimeout = d
+		}
+	}
+}
+
+// WithSignals 设置触发关闭的信号，默认 SIGINT、SIGTERM。
+func WithSignals(sigs ...os.Signal) Option {
+	return func(c *config) {
+		if len(sigs) > 0 {
+			c.signals = sigs
+		}
+	}
+}
+
+// Manager 管理一组 Hook 的启动与关闭，可并发注册。
+type Manager struct {
+	cfg config
+
+	mu      sync.Mutex
+	hooks   []Hook
+	started []Hook // 已成功启动的 Hook，按启动顺序
+	running bool   // Start 已开始
+	stopped bool
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// New 创建一个 Manager。多数服务直接使用包级的 Append / Run 即可。
+func New(opts ...Option) *Manager {
+	cfg := config{
+		stopTimeout: 10 * time.Second,
+		signals:     []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return &Manager{cfg: cfg, shutdown: make(chan struct{})}
+}
+
+// Append 注册一个 Hook。Start 之后注册的 Hook 不会再执行 OnStart，
+// 但会参与 Stop（适用于启动后才创建的连接等只需关闭的组件）。
+func (m *Manager) Append(h Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		m.started = append(m.started, h)
+		return
+	}
+	m.hooks = append(m.hooks, h)
+}
+
+// Start 按优先级依次执行 OnStart。某个 Hook 启动失败时，按相反顺序停止已启动的
+// Hook 并返回该错误。
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	if m.running {
+		m.mu.Unlock()
+		return errors.New("lifecycle: already started")
+	}
+	m.running = true
+	hooks := append([]Hook(nil), m.hooks...)
+	m.mu.Unlock()
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].Priority < hooks[j].Priority })
+
+	for _, h := range hooks {
+		if h.OnStart != nil {
+			if err := coroutines.SafeFuncErr(ctx, h.OnStart); err != nil {
+				err = fmt.Errorf("lifecycle: start %s: %w", h.Name, err)
+				_ = m.Stop(context.WithoutCancel(ctx))
+				return err
+			}
+		}
+		m.mu.Lock()
+		m.started = append(m.started, h)
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop 按启动的相反顺序执行 OnStop，只执行一次。单个 Hook 失败不影响后续 Hook，
+// 失败会记录日志并通过 notify 告警，返回全部错误的 errors.Join。
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return nil
+	}
+	m.stopped = true
+	hooks := m.stopOrder()
+	m.mu.Unlock()
+
+	var errs []error
+	for _, h := range hooks {
+		if h.OnStop == nil {
+			continue
+		}
+		if err := m.stopHook(ctx, h); err != nil {
+			err = fmt.Errorf("lifecycle: stop %s: %w", h.Name, err)
+			log.WithContext(ctx).WithField("action", "lifecycle stop").Error(err.Error())
+			notify.SendErrMessage(ctx, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopOrder 返回需要停止的 Hook（调用方需持有 m.mu）：按优先级从大到小，同优先级后启动的先停止。
+func (m *Manager) stopOrder() []Hook {
+	hooks := append([]Hook(nil), m.started...)
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].Priority < hooks[j].Priority })
+	for i, j := 0, len(hooks)-1; i < j; i, j = i+1, j-1 {
+		hooks[i], hooks[j] = hooks[j], hooks[i]
+	}
+	return hooks
+}
+
+// stopHook 在超时时间内执行 h.OnStop，OnStop 不响应 ctx 时超时返回 ErrStopTimeout。
+func (m *Manager) stopHook(ctx context.Context, h Hook) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = m.cfg.stopTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- coroutines.SafeFuncErr(ctx, h.OnStop) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w after %s", ErrStopTimeout, timeout)
+	}
+}
+
+// Shutdown 让正在等待的 Run 开始关闭，可重复调用。
+func (m *Manager) Shutdown() {
+	m.shutdownOnce.Do(func() { close(m.shutdown) })
+}
+
+// Run 启动全部 Hook，然后阻塞直到收到关闭信号、ctx 取消或调用 Shutdown，
+// 再按相反顺序停止。返回启动错误或停止错误。
+func (m *Manager) Run(ctx context.Context) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, m.cfg.signals...)
+	defer signal.Stop(sig)
+
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+	select {
+	case s := <-sig:
+		log.WithContext(ctx).WithField("signal", s.String()).Info("lifecycle: shutting down")
+	case <-ctx.Done():
+	case <-m.shutdown:
+	}
+	return m.Stop(context.WithoutCancel(ctx))
+}
+
+var defaultManager = New()
+
+// Default 返回包级默认 Manager。
+func Default() *Manager {
+	return defaultManager
+}
+
+// Append 向默认 Manager 注册 Hook。
+func Append(h Hook) {
+	defaultManager.Append(h)
+}
+
+// Run 运行默认 Manager，见 Manager.Run。
+func Run(ctx context.Context) error {
+	return defaultManager.Run(ctx)
+}
+
+// Shutdown 让默认 Manager 开始关闭。
+func Shutdown() {
+	defaultManager.Shutdown()
+}
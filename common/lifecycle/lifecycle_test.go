@@ -0,0 +1,134 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder 记录 Hook 的执行顺序。
+type recorder struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (r *recorder) hook(name string, priority int) Hook {
+	return Hook{
+		Name:     name,
+		Priority: priority,
+		OnStart: func(ctx context.Context) error {
+			r.add("start " + name)
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			r.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+func (r *recorder) add(step string) {
+	r.mu.Lock()
+	r.steps = append(r.steps, step)
+	r.mu.Unlock()
+}
+
+// TestManager_Order 验证按优先级启动、反序停止。
+func TestManager_Order(t *testing.T) {
+	var rec recorder
+	m := New()
+	m.Append(rec.hook("server", PriorityServer))
+	m.Append(rec.hook("db", PriorityStorage))
+	m.Append(rec.hook("mq", PriorityMessaging))
+	m.Append(rec.hook("cache", PriorityStorage))
+
+	ctx := context.Background()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := m.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	want := []string{
+		"start db", "start cache", "start mq", "start server",
+		"stop server", "stop mq", "stop cache", "stop db",
+	}
+	if !reflect.DeepEqual(rec.steps, want) {
+		t.Fatalf("steps = %v, want %v", rec.steps, want)
+	}
+}
+
+// TestManager_StartFailureRollsBack 验证启动失败时停止已启动的组件。
+func TestManager_StartFailureRollsBack(t *testing.T) {
+	var rec recorder
+	m := New()
+	m.Append(rec.hook("db", PriorityStorage))
+	m.Append(Hook{Name: "bad", Priority: PriorityServer, OnStart: func(ctx context.Context) error {
+		return errors.New("port in use")
+	}})
+
+	if err := m.Start(context.Background()); err == nil {
+		t.Fatal("expected Start to fail")
+	}
+	want := []string{"start db", "stop db"}
+	if !reflect.DeepEqual(rec.steps, want) {
+		t.Fatalf("steps = %v, want %v", rec.steps, want)
+	}
+}
+
+// TestManager_StopTimeoutContinues 验证超时或失败的组件不阻断后续组件的停止。
+func TestManager_StopTimeoutContinues(t *testing.T) {
+	var rec recorder
+	m := New(WithStopTimeout(20 * time.Millisecond))
+	m.Append(rec.hook("db", PriorityStorage))
+	m.Append(Hook{Name: "stuck", Priority: PriorityWorker, OnStop: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+	m.Append(Hook{Name: "panicky", Priority: PriorityServer, OnStop: func(ctx context.Context) error {
+		panic("boom")
+	}})
+
+	ctx := context.Background()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	err := m.Stop(ctx)
+	if !errors.Is(err, ErrStopTimeout) {
+		t.Fatalf("expected ErrStopTimeout, got %v", err)
+	}
+	if want := []string{"start db", "stop db"}; !reflect.DeepEqual(rec.steps, want) {
+		t.Fatalf("steps = %v, want %v", rec.steps, want)
+	}
+	if err := m.Stop(ctx); err != nil {
+		t.Fatalf("second Stop should be a no-op, got %v", err)
+	}
+}
+
+// TestManager_RunShutdown 验证 Shutdown 让 Run 停止全部组件后返回。
+func TestManager_RunShutdown(t *testing.T) {
+	var rec recorder
+	m := New()
+	m.Append(rec.hook("db", PriorityStorage))
+
+	done := make(chan error, 1)
+	go func() { done <- m.Run(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	m.Append(Hook{Name: "late", OnStop: StopFunc(func() { rec.add("stop late") })})
+	m.Shutdown()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after Shutdown")
+	}
+	if want := []string{"start db", "stop late", "stop db"}; !reflect.DeepEqual(rec.steps, want) {
+		t.Fatalf("steps = %v, want %v", rec.steps, want)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/dromara/dongle"
+	"github.com/dromara/dongle/crypto"
 	"github.com/dromara/dongle/crypto/cipher"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
@@ -56,6 +57,8 @@ func SHA512Sum(plainText string) string {
 //
 // AES 对称加密
 // 此部分提供了 AES 加密和解密的封装，包括常用的 CBC 和更安全的 GCM 模式。
+// 每次调用都新建 Encrypter / Decrypter：dongle.Encrypt / dongle.Decrypt 是共享实例，
+// 并发不安全，且一次失败后 Error 不会被清除。
 //
 
 // AESHelper encapsulates an AES cipher with a specific mode and padding.
@@ -91,7 +94,7 @@ func NewAESHelperGCM(key []byte) (*AESHelper, error) {
 
 // EncryptBase64 encrypts a string and returns a base64-encoded string. Works for AES CBC mode.
 func (a *AESHelper) EncryptBase64(plainText string) (string, error) {
-	res := dongle.Encrypt.FromString(plainText).ByAes(a.c)
+	res := crypto.NewEncrypter().FromString(plainText).ByAes(a.c)
 	if res.Error != nil {
 		return "", res.Error
 	}
@@ -100,7 +103,7 @@ func (a *AESHelper) EncryptBase64(plainText string) (string, error) {
 
 // EncryptHex encrypts a string and returns a hex-encoded string. Works for AES CBC mode.
 func (a *AESHelper) EncryptHex(plainText string) (string, error) {
-	res := dongle.Encrypt.FromString(plainText).ByAes(a.c)
+	res := crypto.NewEncrypter().FromString(plainText).ByAes(a.c)
 	if res.Error != nil {
 		return "", res.Error
 	}
@@ -109,7 +112,7 @@ func (a *AESHelper) EncryptHex(plainText string) (string, error) {
 
 // DecryptBase64 decrypts a base64-encoded string. Works for AES CBC mode.
 func (a *AESHelper) DecryptBase64(cipherText string) (string, error) {
-	res := dongle.Decrypt.FromBase64String(cipherText).ByAes(a.c)
+	res := crypto.NewDecrypter().FromBase64String(cipherText).ByAes(a.c)
 	if res.Error != nil {
 		return "", res.Error
 	}
@@ -118,7 +121,7 @@ func (a *AESHelper) DecryptBase64(cipherText string) (string, error) {
 
 // DecryptHex decrypts a hex-encoded string. Works for AES CBC mode.
 func (a *AESHelper) DecryptHex(cipherText string) (string, error) {
-	res := dongle.Decrypt.FromHexString(cipherText).ByAes(a.c)
+	res := crypto.NewDecrypter().FromHexString(cipherText).ByAes(a.c)
 	if res.Error != nil {
 		return "", res.Error
 	}
@@ -140,7 +143,7 @@ func EncryptGCM(key []byte, plainText string) (string, []byte, error) {
 	aesCipher.SetKey(key)
 	aesCipher.SetNonce(nonce)
 	aesCipher.SetPadding(cipher.No)
-	res := dongle.Encrypt.FromString(plainText).ByAes(aesCipher)
+	res := crypto.NewEncrypter().FromString(plainText).ByAes(aesCipher)
 	if res.Error != nil {
 		return "", nil, res.Error
 	}
@@ -153,7 +156,7 @@ func DecryptGCM(key, nonce []byte, cipherTextHex string) (string, error) {
 	aesCipher.SetKey(key)
 	aesCipher.SetNonce(nonce)
 	aesCipher.SetPadding(cipher.No)
-	res := dongle.Decrypt.FromHexString(cipherTextHex).ByAes(aesCipher)
+	res := crypto.NewDecrypter().FromHexString(cipherTextHex).ByAes(aesCipher)
 	if res.Error != nil {
 		return "", res.Error
 	}
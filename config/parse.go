@@ -32,6 +32,23 @@ const (
 )
 
 func Parse(configPath string, fileType string, conf any) error {
+	v := newViper(configPath, fileType)
+
+	// 读取配置文件
+	if err := v.ReadInConfig(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("config file read err: %s", configPath))
+	}
+
+	// 将配置映射到结构体（需使用 mapstructure 标签）
+	if err := v.Unmarshal(conf); err != nil {
+		return errors.Wrap(err, "config file unmarshal err")
+	}
+
+	return nil
+}
+
+// newViper 创建读取 configPath 的 viper 实例，按 Parse 的规则确定文件类型
+func newViper(configPath string, fileType string) *viper.Viper {
 	v := viper.New()
 	v.SetConfigFile(configPath)
 
@@ -48,16 +65,5 @@ func Parse(configPath string, fileType string, conf any) error {
 			v.SetConfigType(YAMLConfigType)
 		}
 	}
-
-	// 读取配置文件
-	if err := v.ReadInConfig(); err != nil {
-		return errors.Wrap(err, fmt.Sprintf("config file read err: %s", configPath))
-	}
-
-	// 将配置映射到结构体（需使用 mapstructure 标签）
-	if err := v.Unmarshal(conf); err != nil {
-		return errors.Wrap(err, "config file unmarshal err")
-	}
-
-	return nil
+	return v
 }
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	"github.com/Cotary/go-lib/notify"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// Validator 由配置结构体可选实现：Watch 首次加载与每次热更新时，在切换新配置前调用，
+// 返回 error 时保留旧配置。
+type Validator interface {
+	Validate() error
+}
+
+// WatchOption 配置 Watcher 的行为
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	onError func(err error)
+}
+
+// WithReloadError 设置热更新失败（读取、解析或校验失败）时的回调，
+// 默认通过 notify.SendErrMessage 记录日志并告警。fn 为 nil 时忽略。
+func WithReloadError(fn func(err error)) WatchOption {
+	return func(c *watchConfig) {
+		if fn != nil {
+			c.onError = fn
+		}
+	}
+}
+
+type subscriber[T any] struct {
+	id int
+	fn func(old, new *T)
+}
+
+// Watcher 持有热更新的配置：配置文件变化时重新解析、校验，通过后原子替换，
+// 并按注册顺序通知订阅者。
+//
+// Current 返回的配置以及订阅者收到的 old / new 在多个协程间共享，只读，不要修改。
+type Watcher[T any] struct {
+	configPath string
+	fileType   string
+	cfg        watchConfig
+
+	cur    atomic.Pointer[T]
+	mu     sync.Mutex // 串行化 Reload
+	subMu  sync.Mutex
+	subs   []subscriber[T]
+	nextID int
+}
+
+// Watch 按 Parse 的规则解析 configPath 得到 T，并通过 viper WatchConfig 监听文件变化。
+// 首次加载失败（含 Validate 失败）时返回 error。监听随进程存在，无法停止。
+//
+//	w, err := config.Watch[Conf]("./config.yaml", "")
+//	config.SubscribeField(w, func(c *Conf) string { return c.Logging.Level }, func(old, new string) {
+//	    lib.InitLog(log.NewLogger(w.Current().Logging))
+//	})
+//	config.SubscribeField(w, func(c *Conf) []nodepool.NodeConfig { return c.Nodes }, func(_, nodes []nodepool.NodeConfig) {
+//	    pool.UpdateNodes(nodes)
+//	})
+func Watch[T any](configPath string, fileType string, opts ...WatchOption) (*Watcher[T], error) {
+	w := &Watcher[T]{
+		configPath: configPath,
+		fileType:   fileType,
+		cfg: watchConfig{onError: func(err error) {
+			notify.SendErrMessage(coroutines.NewContext("config-reload"), err)
+		}},
+	}
+	for _, o := range opts {
+		o(&w.cfg)
+	}
+
+	conf, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.cur.Store(conf)
+
+	// viper 在监听协程中自行读取配置，这里只借用它的文件事件；
+	// 每次 Reload 用新的 viper 实例解析，避免与监听协程并发读写同一实例
+	v := newViper(configPath, fileType)
+	v.OnConfigChange(func(fsnotify.Event) {
+		if err := w.Reload(); err != nil {
+			w.cfg.onError(err)
+		}
+	})
+	v.WatchConfig()
+	return w, nil
+}
+
+// Current 返回当前生效的配置
+func (w *Watcher[T]) Current() *T {
+	return w.cur.Load()
+}
+
+// Subscribe 注册配置变更回调，返回取消订阅的函数。
+// 回调在 Reload 所在协程中经 SafeFunc 同步执行，panic 不会影响其它订阅者；
+// 回调内不要调用 Reload。
+func (w *Watcher[T]) Subscribe(fn func(old, new *T)) (unsubscribe func()) {
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	w.nextID++
+	id := w.nextID
+	w.subs = append(w.subs, subscriber[T]{id: id, fn: fn})
+	return func() {
+		w.subMu.Lock()
+		defer w.subMu.Unlock()
+		for i, s := range w.subs {
+			if s.id == id {
+				w.subs = append(w.subs[:i:i], w.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SubscribeField 只在 field 选出的字段发生变化时（reflect.DeepEqual 比较）回调 fn，
+// 便于按字段订阅，如日志级别、节点列表、限流阈值。
+func SubscribeField[T, F any](w *Watcher[T], field func(conf *T) F, fn func(old, new F)) (unsubscribe func()) {
+	return w.Subscribe(func(old, new *T) {
+		o, n := field(old), field(new)
+		if !reflect.DeepEqual(o, n) {
+			fn(o, n)
+		}
+	})
+}
+
+// Reload 立即重新解析配置文件：读取、解析或校验失败时返回 error 并保留旧配置；
+// 新配置与当前配置相同时（编辑器保存常触发多次事件）不通知订阅者。
+func (w *Watcher[T]) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	conf, err := w.load()
+	if err != nil {
+		return err
+	}
+	old := w.cur.Load()
+	if reflect.DeepEqual(old, conf) {
+		return nil
+	}
+	w.cur.Store(conf)
+
+	w.subMu.Lock()
+	subs := append([]subscriber[T](nil), w.subs...)
+	w.subMu.Unlock()
+	ctx := coroutines.NewContext("config-reload")
+	for _, s := range subs {
+		coroutines.SafeFunc(ctx, func(context.Context) { s.fn(old, conf) })
+	}
+	return nil
+}
+
+// load 解析并校验配置文件
+func (w *Watcher[T]) load() (*T, error) {
+	v := newViper(w.configPath, w.fileType)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("config file read err: %s", w.configPath))
+	}
+	conf := new(T)
+	if err := v.Unmarshal(conf); err != nil {
+		return nil, errors.Wrap(err, "config file unmarshal err")
+	}
+	if val, ok := any(conf).(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return nil, errors.Wrap(err, "config validate err")
+		}
+	}
+	return conf, nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testConf struct {
+	Level string   `mapstructure:"level"`
+	Nodes []string `mapstructure:"nodes"`
+	Limit int      `mapstructure:"limit"`
+}
+
+func (c *testConf) Validate() error {
+	if c.Limit <= 0 {
+		return errors.New("limit must be positive")
+	}
+	return nil
+}
+
+func writeConf(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func TestWatch_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConf(t, path, "level: info\nnodes: [a]\nlimit: 10\n")
+
+	// 写文件过程中可能读到半截内容，失败的 Reload 会保留旧配置，这里忽略即可
+	w, err := Watch[testConf](path, "", WithReloadError(func(error) {}))
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if c := w.Current(); c.Level != "info" || c.Limit != 10 {
+		t.Fatalf("unexpected initial config: %+v", c)
+	}
+
+	var (
+		mu     sync.Mutex
+		levels []string
+		nodes  int
+		calls  int
+	)
+	SubscribeField(w, func(c *testConf) string { return c.Level }, func(old, new string) {
+		mu.Lock()
+		levels = append(levels, old+"->"+new)
+		mu.Unlock()
+	})
+	SubscribeField(w, func(c *testConf) []string { return c.Nodes }, func(old, new []string) {
+		mu.Lock()
+		nodes++
+		mu.Unlock()
+	})
+	unsubscribe := w.Subscribe(func(old, new *testConf) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		panic("subscriber panic must not break the others")
+	})
+
+	// 校验失败：保留旧配置，不通知
+	writeConf(t, path, "level: debug\nnodes: [a]\nlimit: 0\n")
+	if err := w.Reload(); err == nil {
+		t.Fatal("expected a validation error")
+	}
+	if w.Current().Level != "info" {
+		t.Fatal("invalid config must not be applied")
+	}
+
+	// 只修改 level：level 订阅者收到通知，nodes 订阅者不收到
+	writeConf(t, path, "level: debug\nnodes: [a]\nlimit: 20\n")
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	// 内容未变：不重复通知
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	unsubscribe()
+
+	mu.Lock()
+	if len(levels) != 1 || levels[0] != "info->debug" || nodes != 0 || calls != 1 {
+		t.Fatalf("unexpected notifications: levels=%v nodes=%d calls=%d", levels, nodes, calls)
+	}
+	mu.Unlock()
+
+	// 文件变化由 WatchConfig 自动触发 Reload
+	writeConf(t, path, "level: debug\nnodes: [a, b]\nlimit: 20\n")
+	deadline := time.Now().Add(3 * time.Second)
+	for len(w.Current().Nodes) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("config was not reloaded on file change: %+v", w.Current())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitDeadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n, c := nodes, calls
+		mu.Unlock()
+		if n == 1 {
+			if c != 1 {
+				t.Fatalf("unsubscribed callback was called again")
+			}
+			break
+		}
+		if time.Now().After(waitDeadline) {
+			t.Fatal("nodes subscriber was not notified")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatch_InitialValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConf(t, path, "level: info\nlimit: 0\n")
+	if _, err := Watch[testConf](path, ""); err == nil {
+		t.Fatal("expected the initial validation to fail")
+	}
+}
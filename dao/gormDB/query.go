@@ -300,6 +300,16 @@ func IsNotNull(col string) Scope {
 	return func(db *gorm.DB) *gorm.DB { return db.Where(col + " IS NOT NULL") }
 }
 
+// WhereFence 生成 col <= token 条件，配合分布式锁的 fencing token 做条件更新：
+// 更新时同时把 col 写为 token，租约已过期的旧持有者（token 更小）的写入会因
+// 条件不满足而影响 0 行，Update 返回 RowsAffectedZero。
+//
+//	err := g.Update(ctx, &Order{Status: 2, FenceToken: m.Token()}, []string{"status", "fence_token"},
+//	    gormDB.WhereIf("id", gormDB.OpEq, id), gormDB.WhereFence("fence_token", m.Token()))
+func WhereFence(col string, token int64) Scope {
+	return func(db *gorm.DB) *gorm.DB { return db.Where(col+" <= ?", token) }
+}
+
 // ===== 分页 =====
 
 // Paginate 执行 count 并回写 Total 到 *p，再根据 Page/PageSize 生成 Limit/Offset。
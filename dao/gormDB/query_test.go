@@ -165,6 +165,12 @@ func TestIsNullAndIsNotNull(t *testing.T) {
 	assert.Contains(t, strings.ToUpper(sql2), "DELETED_AT IS NOT NULL")
 }
 
+func TestWhereFence(t *testing.T) {
+	db := openDryRunDB(t)
+	_, sql := buildSQL(db, WhereFence("fence_token", 42))
+	assert.Contains(t, sql, "fence_token <= 42")
+}
+
 func TestWhereOps(t *testing.T) {
 	db := openDryRunDB(t)
 	_, sql := buildSQL(db,
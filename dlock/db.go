@@ -0,0 +1,567 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	"github.com/Cotary/go-lib/dao/gormDB"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBOption 配置 DBProvider 的选项函数。
+type DBOption func(*dbProviderConfig)
+
+type dbProviderConfig struct {
+	table      string
+	ttl        time.Duration
+	tries      int
+	retryDelay time.Duration
+	watchdog   time.Duration // 续期间隔，0 表示不开启看门狗
+	waitAlert  time.Duration // Lock 等待超过该时长时告警，0 表示不告警
+}
+
+var defaultDBConfig = dbProviderConfig{
+	table:      "dlock_locks",
+	ttl:        8 * time.Second,
+	tries:      32,
+	retryDelay: 500 * time.Millisecond,
+}
+
+// WithDBTable 设置锁表名（默认 "dlock_locks"）。
+func WithDBTable(table string) DBOption {
+	return func(c *dbProviderConfig) { c.table = table }
+}
+
+// WithDBTTL 设置锁的过期时间（默认 8s），过期后其他实例可以接管。
+func WithDBTTL(d time.Duration) DBOption {
+	return func(c *dbProviderConfig) { c.ttl = d }
+}
+
+// WithDBTries 设置获取锁的最大尝试次数（默认 32）。
+func WithDBTries(n int) DBOption {
+	return func(c *dbProviderConfig) { c.tries = n }
+}
+
+// WithDBRetryDelay 设置重试间隔（默认 500ms）。
+func WithDBRetryDelay(d time.Duration) DBOption {
+	return func(c *dbProviderConfig) { c.retryDelay = d }
+}
+
+// WithDBWatchdog 开启看门狗：持锁期间每隔 interval 自动续期，语义同 WithRedisWatchdog。
+// interval <= 0 时取 TTL/3。
+func WithDBWatchdog(interval time.Duration) DBOption {
+	return func(c *dbProviderConfig) {
+		if interval <= 0 {
+			interval = -1 // 在 NewDBProvider 中按最终的 TTL 计算
+		}
+		c.watchdog = interval
+	}
+}
+
+// WithDBWaitAlert 设置 Lock 等待告警阈值：等待超过 d 时通过 notify 发送告警，
+// 告警内容包含当前持有者（默认不告警）。
+func WithDBWaitAlert(d time.Duration) DBOption {
+	return func(c *dbProviderConfig) { c.waitAlert = d }
+}
+
+// dbLock 是锁表的一行：每个 key 一行，首次加锁时创建，释放时只清空 owner，
+// fence 计数器随行保留，保证 fencing token 单调递增。
+type dbLock struct {
+	Name     string    `gorm:"column:name;primaryKey;size:191"`
+	Owner    string    `gorm:"column:owner;size:255;not null;default:''"`
+	ExpireAt time.Time `gorm:"column:expire_at;not null"`
+	Fence    int64     `gorm:"column:fence;not null;default:0"`
+	Holder   string    `gorm:"column:holder;size:1024;not null;default:''"` // 持有者信息（JSON）
+}
+
+// DBProvider 基于数据库锁表的分布式锁工厂，适用于只有 MySQL / Postgres 的部署。
+//
+// 加锁在事务中以 SELECT ... FOR UPDATE 锁住 key 对应的行，owner 为空或已过期
+// （TTL 接管）时写入新的 owner 与过期时间。锁操作不会加入 ctx 中已有的
+// gormDB 事务，始终独立提交。过期判断使用应用服务器时间，各实例需保持时钟同步。
+type DBProvider struct {
+	g   *gormDB.GormDrive
+	cfg dbProviderConfig
+	mon *monitor
+}
+
+// NewDBProvider 创建基于数据库的分布式锁工厂，并自动创建（迁移）锁表。
+func NewDBProvider(g *gormDB.GormDrive, opts ...DBOption) (*DBProvider, error) {
+	cfg := defaultDBConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.watchdog < 0 {
+		cfg.watchdog = cfg.ttl / 3
+	}
+	p := &DBProvider{g: g, cfg: cfg}
+	p.mon = &monitor{waitAlert: cfg.waitAlert, inspect: p.Inspect}
+	if err := p.table(context.Background()).AutoMigrate(&dbLock{}); err != nil {
+		return nil, fmt.Errorf("dlock: migrate lock table: %w", err)
+	}
+	return p, nil
+}
+
+// table 返回不带 ctx 事务的锁表查询。
+func (p *DBProvider) table(ctx context.Context) *gorm.DB {
+	return p.g.DB().WithContext(ctx).Table(p.cfg.table)
+}
+
+// ensure 确保 name 对应的行存在。
+func (p *DBProvider) ensure(ctx context.Context, name string) error {
+	return p.table(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&dbLock{Name: name, ExpireAt: time.Unix(0, 0)}).Error
+}
+
+// acquire 尝试把 name 对应的行占为 owner 所有，返回 fencing token 与到期时刻。
+// admit 不为 nil 时在行锁内做额外检查（读写锁的写者需要确认没有读者）。
+func (p *DBProvider) acquire(ctx context.Context, name, owner string, admit func(tx *gorm.DB, now time.Time) (bool, error)) (int64, time.Time, bool, error) {
+	if err := p.ensure(ctx, name); err != nil {
+		return 0, time.Time{}, false, err
+	}
+
+	var (
+		token int64
+		until time.Time
+		ok    bool
+	)
+	err := p.g.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row dbLock
+		if err := tx.Table(p.cfg.table).Scopes(gormDB.ForUpdate()).
+			Where("name = ?", name).Take(&row).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if admit != nil {
+			admitted, err := admit(tx, now)
+			if err != nil || !admitted {
+				return err
+			}
+		}
+
+		until = now.Add(p.cfg.ttl)
+		res := tx.Table(p.cfg.table).
+			Where("name = ? AND (owner = '' OR expire_at <= ?)", name, now).
+			Updates(map[string]any{"owner": owner, "expire_at": until, "fence": gorm.Expr("fence + 1"), "holder": encodeHolder(ctx, "")})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		ok = true
+		return tx.Table(p.cfg.table).Select("fence").Where("name = ?", name).Scan(&token).Error
+	})
+	return token, until, ok, err
+}
+
+// extend 把 owner 持有的锁续期一个 TTL，锁已不属于 owner 时返回 ErrLeaseLost。
+func (p *DBProvider) extend(ctx context.Context, name, owner string) (time.Time, error) {
+	until := time.Now().Add(p.cfg.ttl)
+	res := p.table(ctx).Where("name = ? AND owner = ?", name, owner).Update("expire_at", until)
+	if res.Error != nil {
+		return time.Time{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return time.Time{}, ErrLeaseLost
+	}
+	return until, nil
+}
+
+// release 释放 owner 持有的锁，锁已被他人接管时为空操作。
+func (p *DBProvider) release(ctx context.Context, name, owner string) error {
+	return p.table(ctx).Where("name = ? AND owner = ?", name, owner).
+		Updates(map[string]any{"owner": "", "expire_at": time.Unix(0, 0), "holder": ""}).Error
+}
+
+// NewMutex 创建一个以 key 为标识的数据库分布式锁。
+func (p *DBProvider) NewMutex(key string) Mutex {
+	return &dbMutex{p: p, name: key, watchdog: p.cfg.watchdog, mon: p.mon}
+}
+
+// Inspect 返回 key 对应互斥锁的当前持有者，无人持有（含已过期）时返回 ErrNotLocked。
+func (p *DBProvider) Inspect(ctx context.Context, key string) (*HolderInfo, error) {
+	var rows []dbLock
+	if err := p.table(ctx).Where("name = ? AND owner <> '' AND expire_at > ?", key, time.Now()).
+		Limit(1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotLocked
+	}
+	if rows[0].Holder == "" {
+		return &HolderInfo{}, nil
+	}
+	return decodeHolder(rows[0].Holder)
+}
+
+// Stats 返回互斥锁的加锁统计。
+func (p *DBProvider) Stats() Stats {
+	return p.mon.snapshot()
+}
+
+// dbMutex 实现 Mutex 接口，每次加锁使用新的随机 owner。
+type dbMutex struct {
+	holder
+	p        *DBProvider
+	name     string
+	label    string // 附加在 owner 之后的信息（选主时为 leader 的 value）
+	watchdog time.Duration
+	mon      *monitor
+	owner    string
+	stopped  chan struct{} // 后台跟踪协程退出时关闭
+}
+
+func (m *dbMutex) try(ctx context.Context) (bool, error) {
+	owner := uuid.NewString() + ":" + m.label
+	token, until, ok, err := m.p.acquire(ctx, m.name, owner, nil)
+	if err != nil || !ok {
+		return false, err
+	}
+	m.owner = owner
+	m.hold(ctx, token, until)
+	return true, nil
+}
+
+func (m *dbMutex) Lock(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { m.mon.waited(ctx, m.name, start, err) }()
+	return retry(ctx, m.p.cfg.tries, m.p.cfg.retryDelay, m.try)
+}
+
+func (m *dbMutex) TryLock(ctx context.Context) (err error) {
+	defer func() { m.mon.tried(err) }()
+	ok, err := m.try(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockFailed
+	}
+	return nil
+}
+
+// hold 开启持有期并在后台跟踪锁的有效期（见 keepAlive）。
+func (m *dbMutex) hold(ctx context.Context, token int64, until time.Time) {
+	l := m.acquired(token)
+	owner := m.owner
+	stopped := make(chan struct{})
+	m.stopped = stopped
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		defer close(stopped)
+		keepAlive(l, until, m.watchdog, func() (time.Time, error) {
+			return m.p.extend(ctx, m.name, owner)
+		})
+	})
+}
+
+func (m *dbMutex) Unlock(ctx context.Context) error {
+	m.released()
+	if m.stopped != nil {
+		<-m.stopped
+		m.stopped = nil
+	}
+	if m.owner == "" {
+		return nil
+	}
+	err := m.p.release(ctx, m.name, m.owner)
+	m.owner = ""
+	return err
+}
+
+// NewRWMutex 创建一个以 key 为标识的数据库读写锁。
+// 写者占用 "<key>:rw" 行，每个读者各占一行 "<key>:rw:r:<id>"；
+// 读写双方都先锁住写者行再做判断，保证互斥。读锁优先。
+func (p *DBProvider) NewRWMutex(key string) RWMutex {
+	name := key + ":rw"
+	return &dbRWMutex{
+		p:      p,
+		writer: &dbMutex{p: p, name: name, watchdog: p.cfg.watchdog, mon: p.mon},
+		reader: name + ":r:" + uuid.NewString(),
+		// 读者行名的范围 [name:r:, name:r;)，';' 是 ':' 的下一个字符
+		readerFrom: name + ":r:",
+		readerTo:   name + ":r;",
+	}
+}
+
+// dbRWMutex 实现 RWMutex 接口。
+type dbRWMutex struct {
+	p          *DBProvider
+	writer     *dbMutex
+	reader     string
+	readerFrom string
+	readerTo   string
+	reading    bool
+	read       holder        // 读锁的持有期
+	stopped    chan struct{} // 读锁后台跟踪协程退出时关闭
+}
+
+// noReaders 清理过期的读者行并确认没有仍然有效的读者。
+func (m *dbRWMutex) noReaders(tx *gorm.DB, now time.Time) (bool, error) {
+	readers := tx.Table(m.p.cfg.table).Where("name >= ? AND name < ?", m.readerFrom, m.readerTo)
+	if err := readers.Session(&gorm.Session{}).Where("expire_at <= ?", now).Delete(&dbLock{}).Error; err != nil {
+		return false, err
+	}
+	var n int64
+	if err := readers.Session(&gorm.Session{}).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+func (m *dbRWMutex) tryWrite(ctx context.Context) (bool, error) {
+	owner := uuid.NewString() + ":"
+	token, until, ok, err := m.p.acquire(ctx, m.writer.name, owner, m.noReaders)
+	if err != nil || !ok {
+		return false, err
+	}
+	m.writer.owner = owner
+	m.writer.hold(ctx, token, until)
+	return true, nil
+}
+
+func (m *dbRWMutex) Lock(ctx context.Context) error {
+	return retry(ctx, m.p.cfg.tries, m.p.cfg.retryDelay, m.tryWrite)
+}
+
+func (m *dbRWMutex) TryLock(ctx context.Context) error {
+	return tryOnce(ctx, m.tryWrite)
+}
+
+func (m *dbRWMutex) Unlock(ctx context.Context) error {
+	return m.writer.Unlock(ctx)
+}
+
+func (m *dbRWMutex) tryRead(ctx context.Context) (bool, error) {
+	if err := m.p.ensure(ctx, m.writer.name); err != nil {
+		return false, err
+	}
+	ok := false
+	var until time.Time
+	err := m.p.g.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row dbLock
+		if err := tx.Table(m.p.cfg.table).Scopes(gormDB.ForUpdate()).
+			Where("name = ?", m.writer.name).Take(&row).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		var writers int64
+		if err := tx.Table(m.p.cfg.table).
+			Where("name = ? AND owner <> '' AND expire_at > ?", m.writer.name, now).
+			Count(&writers).Error; err != nil || writers > 0 {
+			return err
+		}
+		ok = true
+		until = now.Add(m.p.cfg.ttl)
+		return tx.Table(m.p.cfg.table).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"owner", "expire_at"}),
+		}).Create(&dbLock{Name: m.reader, Owner: m.reader, ExpireAt: until}).Error
+	})
+	if err != nil || !ok {
+		return false, err
+	}
+	m.reading = true
+	m.holdRead(ctx, until)
+	return true, nil
+}
+
+// holdRead 开启读锁的持有期并在后台跟踪读者行的有效期（见 keepAlive），
+// 开启看门狗时与写锁一样续期读者行。
+func (m *dbRWMutex) holdRead(ctx context.Context, until time.Time) {
+	l := m.read.acquired(0)
+	stopped := make(chan struct{})
+	m.stopped = stopped
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		defer close(stopped)
+		keepAlive(l, until, m.p.cfg.watchdog, func() (time.Time, error) {
+			return m.p.extend(ctx, m.reader, m.reader)
+		})
+	})
+}
+
+func (m *dbRWMutex) RLock(ctx context.Context) error {
+	return retry(ctx, m.p.cfg.tries, m.p.cfg.retryDelay, m.tryRead)
+}
+
+func (m *dbRWMutex) TryRLock(ctx context.Context) error {
+	return tryOnce(ctx, m.tryRead)
+}
+
+func (m *dbRWMutex) RUnlock(ctx context.Context) error {
+	m.read.released()
+	if m.stopped != nil {
+		<-m.stopped
+		m.stopped = nil
+	}
+	if !m.reading {
+		return nil
+	}
+	m.reading = false
+	return m.p.table(ctx).Where("name = ?", m.reader).Delete(&dbLock{}).Error
+}
+
+// Done 同一时刻只持有读锁或写锁之一：未持有写锁时 writer.Done 已关闭，返回读锁的 channel。
+func (m *dbRWMutex) Done() <-chan struct{} {
+	select {
+	case <-m.writer.Done():
+		return m.read.Done()
+	default:
+		return m.writer.Done()
+	}
+}
+
+// NewSemaphore 创建一个以 key 为标识、最多 n 个持有者的数据库信号量。
+// n 个名额对应 n 行 "<key>:sem:<i>"，每个持有者占用其中一行。
+func (p *DBProvider) NewSemaphore(key string, n int) Semaphore {
+	return &dbSemaphore{p: p, key: key + ":sem:", n: max(n, 1)}
+}
+
+// dbSemaphore 实现 Semaphore 接口。
+type dbSemaphore struct {
+	p    *DBProvider
+	key  string
+	n    int
+	slot *dbMutex
+}
+
+// try 从随机位置开始依次尝试每个名额，减少并发持有者之间的冲突。
+func (s *dbSemaphore) try(ctx context.Context) (bool, error) {
+	start := rand.IntN(s.n)
+	for i := 0; i < s.n; i++ {
+		slot := &dbMutex{p: s.p, name: s.key + strconv.Itoa((start+i)%s.n), watchdog: s.p.cfg.watchdog, mon: s.p.mon}
+		ok, err := slot.try(ctx)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			s.slot = slot
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *dbSemaphore) Lock(ctx context.Context) error {
+	return retry(ctx, s.p.cfg.tries, s.p.cfg.retryDelay, s.try)
+}
+
+func (s *dbSemaphore) TryLock(ctx context.Context) error {
+	return tryOnce(ctx, s.try)
+}
+
+func (s *dbSemaphore) Unlock(ctx context.Context) error {
+	if s.slot == nil {
+		return nil
+	}
+	err := s.slot.Unlock(ctx)
+	s.slot = nil
+	return err
+}
+
+func (s *dbSemaphore) Done() <-chan struct{} {
+	if s.slot == nil {
+		return closedChan
+	}
+	return s.slot.Done()
+}
+
+// tryOnce 只调用一次 try，未成功时返回 ErrLockFailed。
+func tryOnce(ctx context.Context, try func(ctx context.Context) (bool, error)) error {
+	ok, err := try(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockFailed
+	}
+	return nil
+}
+
+// NewElection 创建一个以 name 为标识的数据库选主实例。
+// 与 Redis 后端一样，当选后始终开启看门狗续期（未配置 WithDBWatchdog 时间隔取 TTL/3）。
+func (p *DBProvider) NewElection(name string, opts ...ElectionOption) Election {
+	return &dbElection{
+		leadership: leadership{cfg: newElectionConfig(opts)},
+		p:          p,
+		name:       name + electionKeySuffix,
+	}
+}
+
+// dbElection 以数据库锁竞选，owner 为「随机 id:value」，Leader 从中取出 value。
+type dbElection struct {
+	leadership
+	p    *DBProvider
+	name string
+	mu   *dbMutex
+}
+
+func (e *dbElection) Campaign(ctx context.Context, value string) error {
+	watchdog := e.p.cfg.watchdog
+	if watchdog <= 0 {
+		watchdog = e.p.cfg.ttl / 3
+	}
+	mu := &dbMutex{p: e.p, name: e.name, label: value, watchdog: watchdog}
+
+	for {
+		ok, err := mu.try(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		timer := time.NewTimer(e.p.cfg.retryDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	e.mu = mu
+	e.elected(ctx, mu.Done(), mu.Token())
+	return nil
+}
+
+func (e *dbElection) Resign(ctx context.Context) error {
+	if e.mu == nil {
+		return nil
+	}
+	e.released()
+	err := e.mu.Unlock(ctx)
+	e.mu = nil
+	return err
+}
+
+func (e *dbElection) Leader(ctx context.Context) (string, error) {
+	var owners []string
+	err := e.p.table(ctx).Where("name = ? AND owner <> '' AND expire_at > ?", e.name, time.Now()).
+		Pluck("owner", &owners).Error
+	if err != nil {
+		return "", err
+	}
+	if len(owners) == 0 {
+		return "", ErrNoLeader
+	}
+	if _, value, ok := strings.Cut(owners[0], ":"); ok {
+		return value, nil
+	}
+	return "", errors.New("dlock: malformed leader owner")
+}
+
+// Observe 按 WithDBRetryDelay 的间隔轮询当前 leader。
+func (e *dbElection) Observe(ctx context.Context) <-chan string {
+	return observe(ctx, e.Leader, func() <-chan struct{} {
+		ch := make(chan struct{})
+		time.AfterFunc(e.p.cfg.retryDelay, func() { close(ch) })
+		return ch
+	})
+}
@@ -1,9 +1,15 @@
-// Package dlock 提供统一的分布式锁抽象，支持 Memory / Redis / etcd 三种后端。
+// Package dlock 提供统一的分布式锁抽象，支持 Memory / Redis / etcd / 数据库四种后端。
 //
 // # 核心概念
 //
-//   - Provider：锁工厂，负责创建指定 key 的 Mutex 实例。
-//   - Mutex：互斥锁接口，提供 Lock / TryLock / Unlock 三个操作，均支持 context 取消。
+//   - Provider：锁工厂，负责创建指定 key 的 Mutex / RWMutex / Semaphore / Election 实例。
+//   - Mutex：互斥锁接口，提供 Lock / TryLock / Unlock 三个操作，均支持 context 取消；
+//     Done 返回的 channel 在锁释放或丢失时关闭，Token 返回本次持锁的 fencing token。
+//   - RWMutex：读写锁，读锁之间可并发，写锁独占。
+//   - Semaphore：计数信号量，同一 key 最多 n 个持有者，用于跨实例限制并发数。
+//   - Election：选主，支持竞选、主动放弃、观察当前 leader 以及当选/失去身份回调。
+//   - Inspect / Stats：查看 Mutex 的当前持有者与加锁竞争统计，Lock 等待过久时可通过 notify 告警。
+//   - Runner：跨实例的 SingleRun，语义与 utils.Manager.SingleRun 一致，运行状态存于 RunStore（如 Redis）。
 //
 // # 选型指南
 //
@@ -12,6 +18,7 @@
 //     单 Redis 节点时属于 AP 模型，适用于「效率型」场景（防止重复计算）。
 //   - etcd：基于 Raft 共识 + Lease 的分布式锁，强一致（CP），
 //     适用于「正确性型」场景（防止数据冲突）。
+//   - 数据库（gormDB）：基于锁表 + SELECT ... FOR UPDATE，适用于只有 MySQL / Postgres 的部署。
 //
 // # 快速上手
 //
@@ -28,6 +35,10 @@
 //	p, err := dlock.NewEtcdProvider(etcdClient, dlock.WithEtcdTTL(10))
 //	m := p.NewMutex("my-key")
 //
+//	// --- 数据库 ---
+//	p, err := dlock.NewDBProvider(gormDrive, dlock.WithDBTTL(10*time.Second))
+//	m := p.NewMutex("my-key")
+//
 //	// 通用使用方式
 //	if err := m.Lock(ctx); err != nil { ... }
 //	defer m.Unlock(ctx)
@@ -41,11 +52,33 @@ import (
 // ErrLockFailed 获取锁失败（TryLock 未获取到锁时返回）。
 var ErrLockFailed = errors.New("dlock: failed to acquire lock")
 
-// Provider 是锁工厂接口，每种后端（Memory / Redis / etcd）实现一次。
+// ErrLeaseLost 锁已确定不再属于自己（租约过期、已被他人接管）。
+// 续期时由后端内部使用，Runner.SingleRun 在任务运行期间锁丢失时返回。
+var ErrLeaseLost = errors.New("dlock: lease lost")
+
+// Provider 是锁工厂接口，每种后端（Memory / Redis / etcd / 数据库）实现一次。
 type Provider interface {
 	// NewMutex 创建一个以 key 为标识的互斥锁。
 	// 同一 key 在同一 Provider 下的多个 Mutex 实例之间互斥。
 	NewMutex(key string) Mutex
+
+	// NewRWMutex 创建一个以 key 为标识的读写锁。
+	// 同一 key 的写锁与其它读/写锁互斥，读锁之间可并发持有。
+	NewRWMutex(key string) RWMutex
+
+	// NewSemaphore 创建一个以 key 为标识、最多 n 个并发持有者的计数信号量（n < 1 时按 1 处理）。
+	// 同一 key 的所有实例应使用相同的 n。
+	NewSemaphore(key string, n int) Semaphore
+
+	// NewElection 创建一个以 name 为标识的选主实例。
+	NewElection(name string, opts ...ElectionOption) Election
+
+	// Inspect 返回 key 对应 Mutex 的当前持有者（服务名、请求 ID、加锁时间），
+	// 无人持有时返回 ErrNotLocked。
+	Inspect(ctx context.Context, key string) (*HolderInfo, error)
+
+	// Stats 返回本 Provider 创建的 Mutex 的加锁次数、竞争次数与等待时间统计。
+	Stats() Stats
 }
 
 // Mutex 是分布式互斥锁的统一接口。
@@ -58,4 +91,42 @@ type Mutex interface {
 
 	// Unlock 释放锁。
 	Unlock(ctx context.Context) error
+
+	// Done 返回一个在锁被 Unlock 释放或丢失时关闭的 channel。
+	// 锁丢失指 Redis 锁过期/续期失败、etcd Session 租约过期等情况，
+	// 持锁期间的长任务应 select 该 channel 以便及时中止。未持有锁时返回已关闭的 channel。
+	Done() <-chan struct{}
+
+	// Token 返回本次持锁的 fencing token，未持有锁时返回 0
+	// （Redis 后端需开启 WithRedisFencing，否则始终返回 0）。
+	// 同一 key 上每次成功加锁得到的 token 严格递增，存储层可据此拒绝
+	// 租约已过期但仍在写入的旧持有者（例如 gormDB.WhereFence）。
+	Token() int64
+}
+
+// RWMutex 是分布式读写锁的统一接口。Lock / TryLock / Unlock 操作写锁，
+// RLock / TryRLock / RUnlock 操作读锁，语义与 Mutex 相同。
+// 单个 RWMutex 实例同一时刻只持有一把锁（读或写）。
+type RWMutex interface {
+	Lock(ctx context.Context) error
+	TryLock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+
+	RLock(ctx context.Context) error
+	TryRLock(ctx context.Context) error
+	RUnlock(ctx context.Context) error
+
+	// Done 返回一个在当前持有的读锁或写锁被释放或丢失时关闭的 channel，语义同 Mutex.Done。
+	Done() <-chan struct{}
+}
+
+// Semaphore 是分布式计数信号量的统一接口。每个实例持有一个名额：
+// Lock 阻塞直到获得名额，TryLock 没有空余名额时返回 ErrLockFailed，Unlock 归还名额。
+type Semaphore interface {
+	Lock(ctx context.Context) error
+	TryLock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+
+	// Done 返回一个在名额被 Unlock 归还或丢失时关闭的 channel，语义同 Mutex.Done。
+	Done() <-chan struct{}
 }
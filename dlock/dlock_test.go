@@ -2,10 +2,19 @@ package dlock
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/Cotary/go-lib/common/defined"
+	"github.com/Cotary/go-lib/common/utils"
+	"github.com/Cotary/go-lib/dao/gormDB"
+	"github.com/go-redsync/redsync/v4"
+	goredisv9 "github.com/go-redsync/redsync/v4/redis/goredis/v9"
+	"github.com/redis/go-redis/v9"
 )
 
 // ============================================================
@@ -156,6 +165,437 @@ func TestMemory_UnlockWithoutLock(t *testing.T) {
 	}
 }
 
+func TestMemory_Done(t *testing.T) {
+	p := NewMemoryProvider()
+	m := p.NewMutex("k1")
+	ctx := context.Background()
+
+	select {
+	case <-m.Done():
+	default:
+		t.Fatal("Done should be closed before Lock")
+	}
+
+	if err := m.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	done := m.Done()
+	select {
+	case <-done:
+		t.Fatal("Done should stay open while the lock is held")
+	default:
+	}
+
+	_ = m.Unlock(ctx)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Done should be closed after Unlock")
+	}
+}
+
+func TestMemory_Token(t *testing.T) {
+	p := NewMemoryProvider()
+	ctx := context.Background()
+
+	m := p.NewMutex("k1")
+	if tok := m.Token(); tok != 0 {
+		t.Fatalf("expected token 0 before Lock, got %d", tok)
+	}
+
+	var last int64
+	for i := 0; i < 3; i++ {
+		if err := m.Lock(ctx); err != nil {
+			t.Fatalf("Lock failed: %v", err)
+		}
+		tok := m.Token()
+		if tok <= last {
+			t.Fatalf("token should increase: last=%d got=%d", last, tok)
+		}
+		last = tok
+		_ = m.Unlock(ctx)
+	}
+	if tok := m.Token(); tok != 0 {
+		t.Fatalf("expected token 0 after Unlock, got %d", tok)
+	}
+}
+
+func TestMemory_RWMutex(t *testing.T) {
+	p := NewMemoryProvider()
+	ctx := context.Background()
+
+	r1 := p.NewRWMutex("cfg")
+	r2 := p.NewRWMutex("cfg")
+	w := p.NewRWMutex("cfg")
+
+	if err := r1.RLock(ctx); err != nil {
+		t.Fatalf("RLock failed: %v", err)
+	}
+	if err := r2.TryRLock(ctx); err != nil {
+		t.Fatalf("readers should share the lock: %v", err)
+	}
+	if err := w.TryLock(ctx); err != ErrLockFailed {
+		t.Fatalf("expected ErrLockFailed while readers hold the lock, got %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- w.Lock(ctx) }()
+
+	_ = r1.RUnlock(ctx)
+	select {
+	case <-acquired:
+		t.Fatal("writer should wait for the remaining reader")
+	case <-time.After(50 * time.Millisecond):
+	}
+	_ = r2.RUnlock(ctx)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("Lock failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("writer should acquire once all readers are gone")
+	}
+
+	if err := r1.TryRLock(ctx); err != ErrLockFailed {
+		t.Fatalf("expected ErrLockFailed while the writer holds the lock, got %v", err)
+	}
+	_ = w.Unlock(ctx)
+	if err := r1.TryRLock(ctx); err != nil {
+		t.Fatalf("TryRLock after Unlock should succeed: %v", err)
+	}
+	_ = r1.RUnlock(ctx)
+
+	p.mu.Lock()
+	n := len(p.gates)
+	p.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("expected idle gates to be cleaned up, got %d", n)
+	}
+}
+
+func TestMemory_Semaphore(t *testing.T) {
+	p := NewMemoryProvider()
+	ctx := context.Background()
+
+	const limit = 3
+	var cur, peak int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := p.NewSemaphore("api", limit)
+			if err := s.Lock(ctx); err != nil {
+				t.Errorf("Lock failed: %v", err)
+				return
+			}
+			n := atomic.AddInt64(&cur, 1)
+			for {
+				old := atomic.LoadInt64(&peak)
+				if n <= old || atomic.CompareAndSwapInt64(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt64(&cur, -1)
+			_ = s.Unlock(ctx)
+		}()
+	}
+	wg.Wait()
+	if peak > limit {
+		t.Fatalf("semaphore allowed %d concurrent holders, limit %d", peak, limit)
+	}
+
+	s1 := p.NewSemaphore("one", 1)
+	s2 := p.NewSemaphore("one", 1)
+	if err := s1.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	if err := s2.TryLock(ctx); err != ErrLockFailed {
+		t.Fatalf("expected ErrLockFailed, got %v", err)
+	}
+	_ = s2.Unlock(ctx) // 未持有时 Unlock 不应归还别人的名额
+	if err := s2.TryLock(ctx); err != ErrLockFailed {
+		t.Fatalf("expected ErrLockFailed after a no-op Unlock, got %v", err)
+	}
+	_ = s1.Unlock(ctx)
+}
+
+func TestMemory_RWMutexSemaphoreDone(t *testing.T) {
+	p := NewMemoryProvider()
+	ctx := context.Background()
+
+	rw := p.NewRWMutex("cfg")
+	sem := p.NewSemaphore("api", 1)
+	select {
+	case <-rw.Done():
+	default:
+		t.Fatal("RWMutex Done should be closed before any lock is held")
+	}
+
+	for _, c := range []struct {
+		name   string
+		lock   func(context.Context) error
+		unlock func(context.Context) error
+		done   func() <-chan struct{}
+	}{
+		{"write", rw.Lock, rw.Unlock, rw.Done},
+		{"read", rw.RLock, rw.RUnlock, rw.Done},
+		{"semaphore", sem.Lock, sem.Unlock, sem.Done},
+	} {
+		if err := c.lock(ctx); err != nil {
+			t.Fatalf("%s: lock failed: %v", c.name, err)
+		}
+		done := c.done()
+		select {
+		case <-done:
+			t.Fatalf("%s: Done should stay open while held", c.name)
+		default:
+		}
+		_ = c.unlock(ctx)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: Done should be closed after unlock", c.name)
+		}
+	}
+}
+
+func TestMemory_Election(t *testing.T) {
+	p := NewMemoryProvider()
+	ctx := context.Background()
+
+	elected := make(chan string, 2)
+	lost := make(chan string, 2)
+	newCandidate := func(name string) Election {
+		return p.NewElection("worker",
+			WithOnElected(func(ctx context.Context) {
+				elected <- name
+				<-ctx.Done()
+				lost <- name
+			}),
+		)
+	}
+	e1, e2 := newCandidate("a"), newCandidate("b")
+
+	if _, err := e1.Leader(ctx); !errors.Is(err, ErrNoLeader) {
+		t.Fatalf("expected ErrNoLeader, got %v", err)
+	}
+
+	obsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	leaders := e2.Observe(obsCtx)
+
+	if err := e1.Campaign(ctx, "a"); err != nil {
+		t.Fatalf("Campaign failed: %v", err)
+	}
+	if got := <-elected; got != "a" {
+		t.Fatalf("expected a to be elected, got %s", got)
+	}
+	if got := <-leaders; got != "a" {
+		t.Fatalf("expected to observe a, got %s", got)
+	}
+	if v, err := e2.Leader(ctx); err != nil || v != "a" {
+		t.Fatalf("expected leader a, got %q, %v", v, err)
+	}
+
+	campaigned := make(chan error, 1)
+	go func() { campaigned <- e2.Campaign(ctx, "b") }()
+	select {
+	case <-campaigned:
+		t.Fatal("second candidate should wait while a leads")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done := e1.Done()
+	if err := e1.Resign(ctx); err != nil {
+		t.Fatalf("Resign failed: %v", err)
+	}
+	<-done
+	if got := <-lost; got != "a" {
+		t.Fatalf("expected a to lose leadership, got %s", got)
+	}
+	if err := <-campaigned; err != nil {
+		t.Fatalf("Campaign failed: %v", err)
+	}
+	if got := <-leaders; got != "b" {
+		t.Fatalf("expected to observe b, got %s", got)
+	}
+	_ = e2.Resign(ctx)
+}
+
+// ============================================================
+// 重入与多 key 加锁
+// ============================================================
+
+func TestLock_Reentrant(t *testing.T) {
+	p := NewMemoryProvider()
+	ctx := context.Background()
+
+	ctx1, unlock1, err := Lock(ctx, p, "account:1")
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	// 同一调用链再次加锁不会死锁
+	tctx, cancel := context.WithTimeout(ctx1, time.Second)
+	defer cancel()
+	ctx2, unlock2, err := Lock(tctx, p, "account:1")
+	if err != nil {
+		t.Fatalf("reentrant Lock failed: %v", err)
+	}
+	if err := unlock2(ctx2); err != nil {
+		t.Fatalf("inner unlock failed: %v", err)
+	}
+
+	// 内层释放不影响外层持锁
+	if err := p.NewMutex("account:1").TryLock(ctx); err != ErrLockFailed {
+		t.Fatalf("expected the outer lock to still be held, got %v", err)
+	}
+
+	// 不同 Provider 的同名 key 是不同的锁
+	other := NewMemoryProvider()
+	_, unlockOther, err := Lock(ctx1, other, "account:1")
+	if err != nil {
+		t.Fatalf("Lock on another provider failed: %v", err)
+	}
+	if err := other.NewMutex("account:1").TryLock(ctx); err != ErrLockFailed {
+		t.Fatalf("expected the other provider's lock to be held, got %v", err)
+	}
+	_ = unlockOther(ctx)
+
+	if err := unlock1(ctx1); err != nil {
+		t.Fatalf("outer unlock failed: %v", err)
+	}
+	m := p.NewMutex("account:1")
+	if err := m.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock after outer unlock should succeed: %v", err)
+	}
+	_ = m.Unlock(ctx)
+}
+
+func TestLockAll_NoDeadlock(t *testing.T) {
+	p := NewMemoryProvider()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keys := []string{"account:a", "account:b"}
+			if i%2 == 1 {
+				keys = []string{"account:b", "account:a", "account:b"}
+			}
+			tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			lctx, unlock, err := LockAll(tctx, p, keys...)
+			if err != nil {
+				t.Errorf("LockAll failed: %v", err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+			_ = unlock(lctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLockAll_Rollback(t *testing.T) {
+	p := NewMemoryProvider()
+	ctx := context.Background()
+
+	busy := p.NewMutex("account:b")
+	if err := busy.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	defer busy.Unlock(ctx)
+
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := LockAll(tctx, p, "account:b", "account:a"); err == nil {
+		t.Fatal("expected LockAll to fail while account:b is held")
+	}
+
+	// account:a 已被回滚释放
+	m := p.NewMutex("account:a")
+	if err := m.TryLock(ctx); err != nil {
+		t.Fatalf("expected account:a to be released after rollback: %v", err)
+	}
+	_ = m.Unlock(ctx)
+}
+
+// ============================================================
+// 持有者信息与加锁统计
+// ============================================================
+
+func TestMemory_InspectAndStats(t *testing.T) {
+	p := NewMemoryProvider(WithMemoryWaitAlert(10 * time.Millisecond))
+	ctx := context.WithValue(context.Background(), defined.RequestID, "req-1")
+
+	if _, err := p.Inspect(ctx, "k1"); !errors.Is(err, ErrNotLocked) {
+		t.Fatalf("expected ErrNotLocked before Lock, got %v", err)
+	}
+
+	m1 := p.NewMutex("k1")
+	if err := m1.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	info, err := p.Inspect(ctx, "k1")
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if info.RequestID != "req-1" || info.AcquiredAt.IsZero() {
+		t.Fatalf("unexpected holder: %+v", info)
+	}
+
+	m2 := p.NewMutex("k1")
+	if err := m2.TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed, got %v", err)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	if err := m2.Lock(tctx); err == nil {
+		t.Fatal("expected Lock to time out")
+	}
+	_ = m1.Unlock(ctx)
+	if _, err := p.Inspect(ctx, "k1"); !errors.Is(err, ErrNotLocked) {
+		t.Fatalf("expected ErrNotLocked after Unlock, got %v", err)
+	}
+
+	st := p.Stats()
+	if st.Attempts != 3 || st.Acquired != 1 || st.Contended != 1 || st.Waits != 2 || st.SlowWaits != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if st.MaxWait < 30*time.Millisecond || st.AverageWait() <= 0 {
+		t.Fatalf("unexpected wait time: %+v", st)
+	}
+}
+
+func TestMonitor_AlertCooldown(t *testing.T) {
+	m := &monitor{}
+	now := time.Now()
+	if !m.allowAlert("k1", now) {
+		t.Fatal("first alert should be allowed")
+	}
+	if m.allowAlert("k1", now.Add(time.Second)) {
+		t.Fatal("alert within the cooldown should be suppressed")
+	}
+	if !m.allowAlert("k2", now.Add(time.Second)) {
+		t.Fatal("alerts for other keys should be allowed")
+	}
+
+	// 过了冷却期的记录在下次告警时被清理
+	if !m.allowAlert("k3", now.Add(alertCooldown+2*time.Second)) {
+		t.Fatal("alert for a new key should be allowed")
+	}
+	if len(m.lastAlert) != 1 {
+		t.Fatalf("expected expired alert records to be pruned, got %v", m.lastAlert)
+	}
+}
+
 // ============================================================
 // Provider 接口兼容性编译检查
 // ============================================================
@@ -164,8 +604,696 @@ var (
 	_ Provider = (*MemoryProvider)(nil)
 	_ Provider = (*RedisProvider)(nil)
 	_ Provider = (*EtcdProvider)(nil)
+	_ Provider = (*DBProvider)(nil)
 
 	_ Mutex = (*memoryMutex)(nil)
 	_ Mutex = (*redisMutex)(nil)
 	_ Mutex = (*etcdMutex)(nil)
+	_ Mutex = (*dbMutex)(nil)
+
+	_ RWMutex = (*memoryRWMutex)(nil)
+	_ RWMutex = (*redisRWMutex)(nil)
+	_ RWMutex = (*etcdRWMutex)(nil)
+	_ RWMutex = (*dbRWMutex)(nil)
+
+	_ Semaphore = (*memorySemaphore)(nil)
+	_ Semaphore = (*redisSemaphore)(nil)
+	_ Semaphore = (*etcdSemaphore)(nil)
+	_ Semaphore = (*dbSemaphore)(nil)
+
+	_ Election = (*memoryElection)(nil)
+	_ Election = (*redisElection)(nil)
+	_ Election = (*etcdElection)(nil)
+	_ Election = (*dbElection)(nil)
 )
+
+func TestRunner_SingleRun(t *testing.T) {
+	p := NewMemoryProvider()
+	r := NewRunner(p, NewMemoryRunStore(), WithRunnerPollInterval(10*time.Millisecond))
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.SingleRun(ctx, "job", utils.NoWait, func(ctx context.Context) error {
+			// 嵌套调用同一个 key 不会死锁
+			if _, err := r.SingleRun(ctx, "job", utils.NoWait, func(context.Context) error { return nil }); err != nil {
+				return err
+			}
+			close(started)
+			<-release
+			return nil
+		})
+		done <- err
+	}()
+	<-started
+
+	info, err := r.SingleRun(ctx, "job", utils.NoWait, func(context.Context) error { return nil })
+	if err != utils.ErrRunning || !info.IsRunning || info.RunCount != 1 {
+		t.Fatalf("expected ErrRunning with the running info, got %+v, %v", info, err)
+	}
+	if _, err := r.SingleRun(ctx, "job", 30*time.Millisecond, func(context.Context) error { return nil }); err != utils.ErrRunning {
+		t.Fatalf("expected ErrRunning after the wait timeout, got %v", err)
+	}
+
+	waited := make(chan utils.RunInfo, 1)
+	go func() {
+		info, _ := r.SingleRun(ctx, "job", utils.MustWait, func(context.Context) error { return nil })
+		waited <- info
+	}()
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	if _, err := r.Wait(ctx, "job"); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	if info := <-waited; info.RunCount != 2 {
+		t.Fatalf("expected the waiting run to be the second, got %+v", info)
+	}
+	if info, _ := r.Info(ctx, "job"); info.IsRunning || info.RunCount != 2 {
+		t.Fatalf("unexpected final info: %+v", info)
+	}
+}
+
+func TestRunner_PanicAndStaleInfo(t *testing.T) {
+	p := NewMemoryProvider()
+	store := NewMemoryRunStore()
+	r := NewRunner(p, store)
+	ctx := context.Background()
+
+	if _, err := r.SingleRun(ctx, "job", utils.NoWait, func(context.Context) error { panic("boom") }); err == nil {
+		t.Fatal("expected the panic to be returned as an error")
+	}
+	if _, err := r.SingleRun(ctx, "job", utils.NoWait, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("the lock should be released after a panic: %v", err)
+	}
+
+	// 持有者崩溃残留的 running 标记：锁已不被持有，Info 按未运行返回
+	_, _ = store.Begin(ctx, "job")
+	if info, _ := r.Info(ctx, "job"); info.IsRunning || info.RunCount != 3 {
+		t.Fatalf("expected a stale running flag to be ignored, got %+v", info)
+	}
+}
+
+// ============================================================
+// Redis 后端错误映射
+// ============================================================
+
+func TestRedis_LockError(t *testing.T) {
+	if err := lockError(nil); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if err := lockError(redsync.ErrFailed); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed, got %v", err)
+	}
+	if err := lockError(&redsync.ErrTaken{Nodes: []int{0}}); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed for a taken lock, got %v", err)
+	}
+	other := errors.New("connection refused")
+	if err := lockError(other); err != other {
+		t.Fatalf("expected the original error, got %v", err)
+	}
+}
+
+// ============================================================
+// Redis 后端测试（需要本地 Redis，不可用时跳过）
+// ============================================================
+
+func newTestRedisProvider(t *testing.T, opts ...RedisOption) *RedisProvider {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	opts = append([]RedisOption{WithRedisKeyPrefix("dlock-test:" + t.Name() + ":")}, opts...)
+	return NewRedisProvider(goredisv9.NewPool(client), opts...)
+}
+
+func TestRedis_Watchdog(t *testing.T) {
+	p := newTestRedisProvider(t, WithRedisExpiry(300*time.Millisecond), WithRedisWatchdog(0))
+	ctx := context.Background()
+
+	m1 := p.NewMutex("job")
+	if err := m1.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	// 超过 expiry 后锁仍被续期持有
+	time.Sleep(time.Second)
+	if err := p.NewMutex("job").TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the renewed lock to still be held, got %v", err)
+	}
+	select {
+	case <-m1.Done():
+		t.Fatal("Done should stay open while the watchdog renews the lock")
+	default:
+	}
+
+	done := m1.Done()
+	if err := m1.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	<-done
+
+	m2 := p.NewMutex("job")
+	if err := m2.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock after Unlock should succeed: %v", err)
+	}
+	_ = m2.Unlock(ctx)
+}
+
+func TestRedis_DoneOnExpiry(t *testing.T) {
+	p := newTestRedisProvider(t, WithRedisExpiry(200*time.Millisecond))
+	ctx := context.Background()
+
+	m := p.NewMutex("job")
+	if err := m.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	defer m.Unlock(ctx)
+
+	select {
+	case <-m.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done should be closed once the lock expires")
+	}
+}
+
+func TestRedis_Token(t *testing.T) {
+	p := newTestRedisProvider(t, WithRedisFencing())
+	ctx := context.Background()
+
+	m1 := p.NewMutex("fence")
+	if err := m1.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	first := m1.Token()
+	_ = m1.Unlock(ctx)
+
+	m2 := p.NewMutex("fence")
+	if err := m2.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	defer m2.Unlock(ctx)
+	if second := m2.Token(); second <= first {
+		t.Fatalf("token should increase: first=%d second=%d", first, second)
+	}
+}
+
+func TestRedis_FencingKeysBounded(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	t.Cleanup(func() { _ = client.Close() })
+
+	for _, fencing := range []bool{false, true} {
+		var opts []RedisOption
+		if fencing {
+			opts = append(opts, WithRedisFencing())
+		}
+		p := newTestRedisProvider(t, opts...)
+		prefix := p.cfg.keyPrefix
+		if keys, _ := client.Keys(ctx, prefix+"*").Result(); len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+
+		// 大量不同的 key 加锁、解锁后，只留下 Provider 级的计数器
+		for i := 0; i < 50; i++ {
+			m := p.NewMutex(fmt.Sprintf("k%d", i))
+			if err := m.Lock(ctx); err != nil {
+				t.Fatalf("Lock failed: %v", err)
+			}
+			if tok := m.Token(); (tok > 0) != fencing {
+				t.Fatalf("fencing=%v: unexpected token %d", fencing, tok)
+			}
+			_ = m.Unlock(ctx)
+		}
+		keys, err := client.Keys(ctx, prefix+"*").Result()
+		if err != nil {
+			t.Fatalf("KEYS failed: %v", err)
+		}
+		want := 0
+		if fencing {
+			want = 1
+		}
+		if len(keys) != want {
+			t.Fatalf("fencing=%v: expected %d keys left, got %v", fencing, want, keys)
+		}
+		client.Del(ctx, prefix+fenceKeyName)
+	}
+}
+
+func TestRedis_TokenAfterExpiry(t *testing.T) {
+	p := newTestRedisProvider(t, WithRedisExpiry(100*time.Millisecond), WithRedisTries(1), WithRedisFencing())
+	ctx := context.Background()
+
+	// A 拿到锁后、领取 token 前停顿到锁过期，期间 B 获得锁并领取 token
+	a := p.NewMutex("fence").(*redisMutex)
+	if err := a.mu.LockContext(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	b := p.NewMutex("fence")
+	if err := b.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	defer b.Unlock(ctx)
+
+	if err := a.hold(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the expired holder to be refused a token, got %v", err)
+	}
+	if a.Token() != 0 {
+		t.Fatalf("expired holder should not hold a token, got %d", a.Token())
+	}
+}
+
+func TestRedis_RWMutex(t *testing.T) {
+	p := newTestRedisProvider(t, WithRedisTries(1))
+	ctx := context.Background()
+
+	r := p.NewRWMutex("cfg")
+	w := p.NewRWMutex("cfg")
+	if err := r.RLock(ctx); err != nil {
+		t.Fatalf("RLock failed: %v", err)
+	}
+	if err := w.Lock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed while a reader holds the lock, got %v", err)
+	}
+	_ = r.RUnlock(ctx)
+	if err := w.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock after RUnlock should succeed: %v", err)
+	}
+	if err := r.TryRLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed while the writer holds the lock, got %v", err)
+	}
+	_ = w.Unlock(ctx)
+}
+
+func TestRedis_Semaphore(t *testing.T) {
+	p := newTestRedisProvider(t, WithRedisExpiry(300*time.Millisecond))
+	ctx := context.Background()
+
+	s1 := p.NewSemaphore("api", 2)
+	s2 := p.NewSemaphore("api", 2)
+	s3 := p.NewSemaphore("api", 2)
+	if err := s1.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	if err := s2.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	if err := s3.TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed when the semaphore is full, got %v", err)
+	}
+	_ = s1.Unlock(ctx)
+	if err := s3.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock after Unlock should succeed: %v", err)
+	}
+
+	// 持有者过期后名额自动回收
+	time.Sleep(400 * time.Millisecond)
+	if err := s1.TryLock(ctx); err != nil {
+		t.Fatalf("expired holders should free their slots: %v", err)
+	}
+	_ = s1.Unlock(ctx)
+}
+
+func TestRedis_RWMutexSemaphoreWatchdog(t *testing.T) {
+	p := newTestRedisProvider(t, WithRedisExpiry(300*time.Millisecond), WithRedisWatchdog(0), WithRedisTries(1))
+	ctx := context.Background()
+
+	r := p.NewRWMutex("cfg")
+	s := p.NewSemaphore("api", 1)
+	if err := r.RLock(ctx); err != nil {
+		t.Fatalf("RLock failed: %v", err)
+	}
+	if err := s.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	// 超过 expiry 后读者与名额仍被续期持有
+	time.Sleep(time.Second)
+	if err := p.NewRWMutex("cfg").TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the renewed reader to block the writer, got %v", err)
+	}
+	if err := p.NewSemaphore("api", 1).TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the renewed slot to still be held, got %v", err)
+	}
+	select {
+	case <-r.Done():
+		t.Fatal("RWMutex Done should stay open while the watchdog renews the reader")
+	case <-s.Done():
+		t.Fatal("Semaphore Done should stay open while the watchdog renews the slot")
+	default:
+	}
+
+	done := s.Done()
+	_ = s.Unlock(ctx)
+	<-done
+	_ = r.RUnlock(ctx)
+
+	// 未开启看门狗时持有者到期即判定丢失
+	p = newTestRedisProvider(t, WithRedisExpiry(200*time.Millisecond))
+	w := p.NewRWMutex("cfg")
+	if err := w.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	defer w.Unlock(ctx)
+	select {
+	case <-w.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done should be closed once the writer expires")
+	}
+}
+
+func TestRedis_Election(t *testing.T) {
+	p := newTestRedisProvider(t, WithRedisExpiry(300*time.Millisecond), WithRedisRetryDelay(20*time.Millisecond))
+	ctx := context.Background()
+
+	e1 := p.NewElection("worker")
+	e2 := p.NewElection("worker")
+	if err := e1.Campaign(ctx, "a"); err != nil {
+		t.Fatalf("Campaign failed: %v", err)
+	}
+
+	// 看门狗续期保证 leader 身份超过 expiry 后仍然有效
+	time.Sleep(600 * time.Millisecond)
+	if v, err := e2.Leader(ctx); err != nil || v != "a" {
+		t.Fatalf("expected leader a, got %q, %v", v, err)
+	}
+
+	campaignCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := e2.Campaign(campaignCtx, "b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the second candidate to time out, got %v", err)
+	}
+
+	_ = e1.Resign(ctx)
+	if err := e2.Campaign(ctx, "b"); err != nil {
+		t.Fatalf("Campaign after Resign failed: %v", err)
+	}
+	if v, err := e1.Leader(ctx); err != nil || v != "b" {
+		t.Fatalf("expected leader b, got %q, %v", v, err)
+	}
+	_ = e2.Resign(ctx)
+	if _, err := e1.Leader(ctx); !errors.Is(err, ErrNoLeader) {
+		t.Fatalf("expected ErrNoLeader after Resign, got %v", err)
+	}
+}
+
+func TestRedis_Runner(t *testing.T) {
+	p := newTestRedisProvider(t)
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	t.Cleanup(func() { _ = client.Close() })
+	prefix := "dlock-test:" + t.Name() + ":info:"
+	ctx := context.Background()
+	_ = client.Del(ctx, prefix+"job").Err()
+
+	r1 := NewRunner(p, NewRedisRunStore(client, prefix))
+	r2 := NewRunner(p, NewRedisRunStore(client, prefix))
+	_, err := r1.SingleRun(ctx, "job", utils.NoWait, func(ctx context.Context) error {
+		info, err := r2.SingleRun(ctx, "job", utils.NoWait, func(context.Context) error { return nil })
+		if err != utils.ErrRunning || !info.IsRunning || info.RunCount != 1 {
+			t.Errorf("expected ErrRunning from the other runner, got %+v, %v", info, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SingleRun failed: %v", err)
+	}
+	if info, err := r2.Info(ctx, "job"); err != nil || info.IsRunning || info.RunCount != 1 || info.StartTime.IsZero() {
+		t.Fatalf("unexpected info: %+v, %v", info, err)
+	}
+}
+
+// ============================================================
+// 数据库后端测试（SQLite 文件库，无外部依赖）
+// ============================================================
+
+func newTestDBProvider(t *testing.T, opts ...DBOption) *DBProvider {
+	t.Helper()
+	dir := t.TempDir()
+	g, err := gormDB.NewGorm(&gormDB.GormConfig{
+		Driver:   "sqlite",
+		Dsn:      []string{dir + "/lock.db"},
+		MaxOpens: 1, // SQLite 不支持并发写事务
+		LogDir:   dir,
+		LogLevel: "silent",
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = g.Close() })
+	p, err := NewDBProvider(g, opts...)
+	if err != nil {
+		t.Fatalf("NewDBProvider failed: %v", err)
+	}
+	return p
+}
+
+func TestDB_Mutex(t *testing.T) {
+	p := newTestDBProvider(t, WithDBTries(1))
+	ctx := context.Background()
+
+	m1 := p.NewMutex("job")
+	m2 := p.NewMutex("job")
+	if err := m1.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	first := m1.Token()
+	if err := m2.TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed, got %v", err)
+	}
+	if err := m2.Lock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed after the tries are used up, got %v", err)
+	}
+	if err := m1.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	if err := m2.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock after Unlock should succeed: %v", err)
+	}
+	if second := m2.Token(); second <= first {
+		t.Fatalf("token should increase: first=%d second=%d", first, second)
+	}
+	_ = m2.Unlock(ctx)
+}
+
+func TestDB_Takeover(t *testing.T) {
+	p := newTestDBProvider(t, WithDBTTL(200*time.Millisecond))
+	ctx := context.Background()
+
+	m1 := p.NewMutex("job")
+	if err := m1.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	select {
+	case <-m1.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done should be closed once the lock expires")
+	}
+
+	// 过期后被其他实例接管，旧持有者的 Unlock 不影响新持有者
+	m2 := p.NewMutex("job")
+	if err := m2.TryLock(ctx); err != nil {
+		t.Fatalf("expired lock should be taken over: %v", err)
+	}
+	_ = m1.Unlock(ctx)
+	if err := p.NewMutex("job").TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the new holder to keep the lock, got %v", err)
+	}
+	_ = m2.Unlock(ctx)
+}
+
+func TestDB_Watchdog(t *testing.T) {
+	p := newTestDBProvider(t, WithDBTTL(300*time.Millisecond), WithDBWatchdog(0))
+	ctx := context.Background()
+
+	m := p.NewMutex("job")
+	if err := m.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	time.Sleep(time.Second)
+	if err := p.NewMutex("job").TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the renewed lock to still be held, got %v", err)
+	}
+	_ = m.Unlock(ctx)
+}
+
+func TestDB_RWMutexSemaphoreWatchdog(t *testing.T) {
+	p := newTestDBProvider(t, WithDBTTL(300*time.Millisecond), WithDBWatchdog(0), WithDBTries(1))
+	ctx := context.Background()
+
+	w := p.NewRWMutex("cfg")
+	r := p.NewRWMutex("cfg-read")
+	s := p.NewSemaphore("api", 1)
+	if err := w.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if err := r.RLock(ctx); err != nil {
+		t.Fatalf("RLock failed: %v", err)
+	}
+	if err := s.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	// 超过 TTL 后写者、读者与名额仍被续期持有
+	time.Sleep(time.Second)
+	if err := p.NewRWMutex("cfg").TryRLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the renewed writer to still be held, got %v", err)
+	}
+	if err := p.NewRWMutex("cfg-read").TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the renewed reader to block the writer, got %v", err)
+	}
+	if err := p.NewSemaphore("api", 1).TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected the renewed slot to still be held, got %v", err)
+	}
+	for _, done := range []<-chan struct{}{w.Done(), r.Done(), s.Done()} {
+		select {
+		case <-done:
+			t.Fatal("Done should stay open while the watchdog renews the lock")
+		default:
+		}
+	}
+
+	_ = w.Unlock(ctx)
+	_ = r.RUnlock(ctx)
+	_ = s.Unlock(ctx)
+}
+
+func TestDB_RWMutex(t *testing.T) {
+	p := newTestDBProvider(t)
+	ctx := context.Background()
+
+	r1 := p.NewRWMutex("cfg")
+	r2 := p.NewRWMutex("cfg")
+	w := p.NewRWMutex("cfg")
+	if err := r1.RLock(ctx); err != nil {
+		t.Fatalf("RLock failed: %v", err)
+	}
+	if err := r2.TryRLock(ctx); err != nil {
+		t.Fatalf("readers should share the lock: %v", err)
+	}
+	if err := w.TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed while readers hold the lock, got %v", err)
+	}
+	_ = r1.RUnlock(ctx)
+	_ = r2.RUnlock(ctx)
+	if err := w.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock after readers left should succeed: %v", err)
+	}
+	if err := r1.TryRLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed while the writer holds the lock, got %v", err)
+	}
+	_ = w.Unlock(ctx)
+}
+
+func TestDB_Semaphore(t *testing.T) {
+	p := newTestDBProvider(t)
+	ctx := context.Background()
+
+	s1 := p.NewSemaphore("api", 2)
+	s2 := p.NewSemaphore("api", 2)
+	s3 := p.NewSemaphore("api", 2)
+	if err := s1.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	if err := s2.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	if err := s3.TryLock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed when the semaphore is full, got %v", err)
+	}
+	_ = s1.Unlock(ctx)
+	if err := s3.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock after Unlock should succeed: %v", err)
+	}
+	_ = s2.Unlock(ctx)
+	_ = s3.Unlock(ctx)
+}
+
+func TestDB_Election(t *testing.T) {
+	p := newTestDBProvider(t, WithDBRetryDelay(20*time.Millisecond))
+	ctx := context.Background()
+
+	e1 := p.NewElection("worker")
+	e2 := p.NewElection("worker")
+	if err := e1.Campaign(ctx, "a"); err != nil {
+		t.Fatalf("Campaign failed: %v", err)
+	}
+	if v, err := e2.Leader(ctx); err != nil || v != "a" {
+		t.Fatalf("expected leader a, got %q, %v", v, err)
+	}
+
+	campaigned := make(chan error, 1)
+	go func() { campaigned <- e2.Campaign(ctx, "b") }()
+	_ = e1.Resign(ctx)
+	if err := <-campaigned; err != nil {
+		t.Fatalf("Campaign failed: %v", err)
+	}
+	if v, err := e1.Leader(ctx); err != nil || v != "b" {
+		t.Fatalf("expected leader b, got %q, %v", v, err)
+	}
+	_ = e2.Resign(ctx)
+	if _, err := e1.Leader(ctx); !errors.Is(err, ErrNoLeader) {
+		t.Fatalf("expected ErrNoLeader after Resign, got %v", err)
+	}
+}
+
+func TestDB_Inspect(t *testing.T) {
+	p := newTestDBProvider(t, WithDBTries(1))
+	ctx := context.WithValue(context.Background(), defined.RequestID, "req-1")
+
+	if _, err := p.Inspect(ctx, "job"); !errors.Is(err, ErrNotLocked) {
+		t.Fatalf("expected ErrNotLocked before Lock, got %v", err)
+	}
+	m := p.NewMutex("job")
+	if err := m.Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	info, err := p.Inspect(ctx, "job")
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if info.RequestID != "req-1" || info.AcquiredAt.IsZero() {
+		t.Fatalf("unexpected holder: %+v", info)
+	}
+	if err := p.NewMutex("job").Lock(ctx); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected ErrLockFailed, got %v", err)
+	}
+
+	_ = m.Unlock(ctx)
+	if _, err := p.Inspect(ctx, "job"); !errors.Is(err, ErrNotLocked) {
+		t.Fatalf("expected ErrNotLocked after Unlock, got %v", err)
+	}
+	if st := p.Stats(); st.Attempts != 2 || st.Acquired != 1 || st.Contended != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestDB_RunnerLeaseLost(t *testing.T) {
+	p := newTestDBProvider(t, WithDBTTL(200*time.Millisecond))
+	r := NewRunner(p, NewMemoryRunStore())
+	ctx := context.Background()
+
+	// 未开启看门狗，锁在任务运行期间过期
+	_, err := r.SingleRun(ctx, "job", utils.NoWait, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+			return errors.New("ctx should be cancelled once the lease is lost")
+		}
+	})
+	if !errors.Is(err, ErrLeaseLost) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+
+	if _, err := r.SingleRun(ctx, "job", utils.NoWait, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("SingleRun failed: %v", err)
+	}
+}
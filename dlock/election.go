@@ -0,0 +1,114 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+)
+
+// ErrNoLeader 当前没有 leader（Leader 返回）。
+var ErrNoLeader = errors.New("dlock: no leader")
+
+// Election 是选主的统一接口。同一 name 在同一 Provider 下的多个 Election 实例竞选同一个 leader 位置。
+type Election interface {
+	// Campaign 以 value（通常是实例标识，如 hostname）参与竞选，阻塞直到当选或 ctx 被取消。
+	Campaign(ctx context.Context, value string) error
+
+	// Resign 主动放弃 leader 身份，未当选时为空操作。
+	Resign(ctx context.Context) error
+
+	// Leader 返回当前 leader 的 value，没有 leader 时返回 ErrNoLeader。
+	Leader(ctx context.Context) (string, error)
+
+	// Observe 每当出现新的 leader 时推送其 value，ctx 取消后 channel 关闭。
+	Observe(ctx context.Context) <-chan string
+
+	// Done 返回一个在失去 leader 身份（Resign 或租约丢失）时关闭的 channel。
+	// 未当选时返回已关闭的 channel。
+	Done() <-chan struct{}
+}
+
+// ElectionOption 配置 Election 的选项函数。
+type ElectionOption func(*electionConfig)
+
+type electionConfig struct {
+	onElected func(ctx context.Context)
+	onLost    func()
+}
+
+// WithOnElected 设置当选回调，在独立协程中执行。
+// ctx 在失去 leader 身份时取消，适合直接运行单例后台任务。
+func WithOnElected(fn func(ctx context.Context)) ElectionOption {
+	return func(c *electionConfig) { c.onElected = fn }
+}
+
+// WithOnLost 设置失去 leader 身份（Resign 或租约丢失）时的回调。
+func WithOnLost(fn func()) ElectionOption {
+	return func(c *electionConfig) { c.onLost = fn }
+}
+
+func newElectionConfig(opts []ElectionOption) electionConfig {
+	var cfg electionConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return cfg
+}
+
+// leadership 管理当选后的持有期与回调，嵌入各后端的 Election 实现以提供 Done 方法。
+type leadership struct {
+	holder
+	cfg electionConfig
+}
+
+// elected 在当选后调用：开启持有期，lost 关闭时判定失去 leader 身份（lost 为 nil 表示只能主动 Resign），
+// 并触发 OnElected / OnLost 回调。
+func (l *leadership) elected(ctx context.Context, lost <-chan struct{}, token int64) {
+	le := l.acquired(token)
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if l.cfg.onElected != nil {
+		coroutines.SafeGo(ctx, l.cfg.onElected)
+	}
+	coroutines.SafeGo(ctx, func(ctx context.Context) {
+		select {
+		case <-lost:
+			le.end()
+		case <-le.done:
+		}
+		cancel()
+		if l.cfg.onLost != nil {
+			l.cfg.onLost()
+		}
+	})
+}
+
+// observe 在 leader 变化时把新的 value 推送到 channel，wait 返回下一次检查前需要等待的 channel。
+// 供没有原生 watch 能力的后端使用。
+func observe(ctx context.Context, leader func(ctx context.Context) (string, error), wait func() <-chan struct{}) <-chan string {
+	ch := make(chan string)
+	coroutines.SafeGo(ctx, func(ctx context.Context) {
+		defer close(ch)
+		var last string
+		for {
+			next := wait()
+			v, err := leader(ctx)
+			if errors.Is(err, ErrNoLeader) {
+				last = ""
+			} else if err == nil && v != last {
+				select {
+				case ch <- v:
+				case <-ctx.Done():
+					return
+				}
+				last = v
+			}
+			select {
+			case <-next:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+	return ch
+}
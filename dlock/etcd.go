@@ -3,7 +3,9 @@ package dlock
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/Cotary/go-lib/common/coroutines"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
 )
@@ -14,6 +16,7 @@ type EtcdOption func(*etcdProviderConfig)
 type etcdProviderConfig struct {
 	ttl       int // Session 的 TTL（秒），也决定锁的租约时长
 	keyPrefix string
+	waitAlert time.Duration // Lock 等待超过该时长时告警，0 表示不告警
 }
 
 var defaultEtcdConfig = etcdProviderConfig{
@@ -32,10 +35,17 @@ func WithEtcdKeyPrefix(prefix string) EtcdOption {
 	return func(c *etcdProviderConfig) { c.keyPrefix = prefix }
 }
 
+// WithEtcdWaitAlert 设置 Lock 等待告警阈值：等待超过 d 时通过 notify 发送告警，
+// 告警内容包含当前持有者（默认不告警）。
+func WithEtcdWaitAlert(d time.Duration) EtcdOption {
+	return func(c *etcdProviderConfig) { c.waitAlert = d }
+}
+
 // EtcdProvider 基于 etcd concurrency 的分布式锁工厂。
 type EtcdProvider struct {
 	client *clientv3.Client
 	cfg    etcdProviderConfig
+	mon    *monitor
 }
 
 // NewEtcdProvider 创建基于 etcd 的分布式锁工厂。
@@ -44,32 +54,58 @@ func NewEtcdProvider(client *clientv3.Client, opts ...EtcdOption) *EtcdProvider
 	for _, o := range opts {
 		o(&cfg)
 	}
-	return &EtcdProvider{
+	p := &EtcdProvider{
 		client: client,
 		cfg:    cfg,
 	}
+	p.mon = &monitor{waitAlert: cfg.waitAlert, inspect: p.Inspect}
+	return p
 }
 
 // NewMutex 创建一个以 key 为标识的 etcd 分布式锁。
 func (p *EtcdProvider) NewMutex(key string) Mutex {
 	return &etcdMutex{
 		client: p.client,
+		key:    key,
 		pfx:    p.cfg.keyPrefix + key,
 		ttl:    p.cfg.ttl,
+		mon:    p.mon,
+	}
+}
+
+// Inspect 返回 key 对应互斥锁的当前持有者，无人持有时返回 ErrNotLocked。
+func (p *EtcdProvider) Inspect(ctx context.Context, key string) (*HolderInfo, error) {
+	resp, err := p.client.Get(ctx, p.cfg.keyPrefix+key+holderKeySuffix)
+	if err != nil {
+		return nil, err
 	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotLocked
+	}
+	return decodeHolder(string(resp.Kvs[0].Value))
+}
+
+// Stats 返回互斥锁的加锁统计。
+func (p *EtcdProvider) Stats() Stats {
+	return p.mon.snapshot()
 }
 
 // etcdMutex 每次 Lock 时创建 Session + concurrency.Mutex，Unlock 后释放 Session。
 // 这保证了每次获取锁都使用独立的租约，避免 Session 复用带来的生命周期管理问题。
 type etcdMutex struct {
+	holder
 	client  *clientv3.Client
+	key     string
 	pfx     string
 	ttl     int
+	mon     *monitor
 	session *concurrency.Session
 	mu      *concurrency.Mutex
 }
 
-func (m *etcdMutex) Lock(ctx context.Context) error {
+func (m *etcdMutex) Lock(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { m.mon.waited(ctx, m.key, start, err) }()
 	session, err := concurrency.NewSession(m.client,
 		concurrency.WithTTL(m.ttl),
 		concurrency.WithContext(ctx),
@@ -86,10 +122,12 @@ func (m *etcdMutex) Lock(ctx context.Context) error {
 
 	m.session = session
 	m.mu = mu
+	m.hold(ctx, session, mu.Header().Revision)
 	return nil
 }
 
-func (m *etcdMutex) TryLock(ctx context.Context) error {
+func (m *etcdMutex) TryLock(ctx context.Context) (err error) {
+	defer func() { m.mon.tried(err) }()
 	session, err := concurrency.NewSession(m.client,
 		concurrency.WithTTL(m.ttl),
 		concurrency.WithContext(ctx),
@@ -109,10 +147,29 @@ func (m *etcdMutex) TryLock(ctx context.Context) error {
 
 	m.session = session
 	m.mu = mu
+	m.hold(ctx, session, mu.Header().Revision)
 	return nil
 }
 
+// hold 写入持有者信息并开启持有期，Session 租约过期（如与 etcd 失联超过 TTL）时关闭 Done。
+// 加锁成功时的集群 revision 即为 fencing token：后一个持有者必然在前一个
+// 删除锁 key 之后才获得锁，revision 严格递增。
+// 持有者信息绑定在 Session 租约上，随 Unlock 关闭 Session 或租约过期自动删除；
+// 它仅用于排查，写入失败不影响加锁。
+func (m *etcdMutex) hold(ctx context.Context, session *concurrency.Session, revision int64) {
+	_, _ = m.client.Put(ctx, m.pfx+holderKeySuffix, encodeHolder(ctx, ""), clientv3.WithLease(session.Lease()))
+	l := m.acquired(revision)
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		select {
+		case <-session.Done():
+			l.end()
+		case <-l.done:
+		}
+	})
+}
+
 func (m *etcdMutex) Unlock(ctx context.Context) error {
+	m.released()
 	if m.mu == nil {
 		return nil
 	}
@@ -0,0 +1,109 @@
+package dlock
+
+import (
+	"context"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// NewElection 创建一个以 name 为标识的 etcd 选主实例（基于 concurrency.Election）。
+// Session 租约过期即判定失去 leader 身份。
+func (p *EtcdProvider) NewElection(name string, opts ...ElectionOption) Election {
+	return &etcdElection{
+		leadership: leadership{cfg: newElectionConfig(opts)},
+		client:     p.client,
+		pfx:        p.cfg.keyPrefix + name + electionKeySuffix,
+		ttl:        p.cfg.ttl,
+	}
+}
+
+// etcdElection 与 etcdMutex 一样，每次 Campaign 创建独立的 Session，Resign 后关闭。
+type etcdElection struct {
+	leadership
+	client  *clientv3.Client
+	pfx     string
+	ttl     int
+	session *concurrency.Session
+	e       *concurrency.Election
+}
+
+func (e *etcdElection) Campaign(ctx context.Context, value string) error {
+	session, err := concurrency.NewSession(e.client,
+		concurrency.WithTTL(e.ttl),
+		concurrency.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	el := concurrency.NewElection(session, e.pfx)
+	if err := el.Campaign(ctx, value); err != nil {
+		_ = session.Close()
+		return err
+	}
+
+	e.session = session
+	e.e = el
+	e.elected(ctx, session.Done(), el.Rev())
+	return nil
+}
+
+func (e *etcdElection) Resign(ctx context.Context) error {
+	if e.e == nil {
+		return nil
+	}
+	e.released()
+	err := e.e.Resign(ctx)
+	_ = e.session.Close()
+	e.e = nil
+	e.session = nil
+	return err
+}
+
+func (e *etcdElection) Leader(ctx context.Context) (string, error) {
+	resp, err := e.client.Get(ctx, e.pfx+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", ErrNoLeader
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// Observe 基于 etcd watch：每次读取当前 leader 后，从读取时的 revision 开始监听
+// 前缀下的变化，有变化再重新读取，不会遗漏 leader 更替。
+func (e *etcdElection) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	coroutines.SafeGo(ctx, func(ctx context.Context) {
+		defer close(ch)
+		var last string
+		for {
+			resp, err := e.client.Get(ctx, e.pfx+"/", clientv3.WithFirstCreate()...)
+			if err != nil {
+				return
+			}
+			if len(resp.Kvs) == 0 {
+				last = ""
+			} else if v := string(resp.Kvs[0].Value); v != last {
+				select {
+				case ch <- v:
+				case <-ctx.Done():
+					return
+				}
+				last = v
+			}
+
+			wctx, cancel := context.WithCancel(ctx)
+			wch := e.client.Watch(wctx, e.pfx+"/", clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+			wr, ok := <-wch
+			cancel()
+			if !ok || wr.Err() != nil {
+				return
+			}
+		}
+	})
+	return ch
+}
@@ -0,0 +1,227 @@
+package dlock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+const (
+	etcdKindRead  = "r"
+	etcdKindWrite = "w"
+)
+
+// etcdQueue 在 pfx 下按创建 revision 排队：每个持有者写入一个绑定 Session 租约的
+// key（pfx/<kind>/<lease>），admit 根据排在自己前面的 key 判断能否持有。
+// 与 concurrency.Mutex 一样，每次加锁使用独立的 Session，释放时关闭；
+// Session 租约过期时持有期结束（Done 关闭）。
+type etcdQueue struct {
+	holder
+	client  *clientv3.Client
+	pfx     string
+	ttl     int
+	session *concurrency.Session
+	key     string
+}
+
+// acquire 入队并等待 admit 成功。block 为 false 时不等待，无法持有则出队并返回 ErrLockFailed。
+func (q *etcdQueue) acquire(ctx context.Context, kind string, block bool, admit func(ahead []string) bool) error {
+	session, err := concurrency.NewSession(q.client,
+		concurrency.WithTTL(q.ttl),
+		concurrency.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s/%x", q.pfx, kind, session.Lease())
+	resp, err := q.client.Put(ctx, key, "", clientv3.WithLease(session.Lease()))
+	if err != nil {
+		_ = session.Close()
+		return err
+	}
+	myRev := resp.Header.Revision
+
+	for {
+		ok, rev, err := q.ready(ctx, myRev, admit)
+		if err == nil && ok {
+			q.session = session
+			q.key = key
+			q.hold(ctx, session)
+			return nil
+		}
+		if err == nil && !block {
+			err = ErrLockFailed
+		}
+		if err == nil {
+			err = q.waitDelete(ctx, session, rev)
+		}
+		if err != nil {
+			_, _ = q.client.Delete(context.WithoutCancel(ctx), key)
+			_ = session.Close()
+			return err
+		}
+	}
+}
+
+// ready 读取排在 myRev 之前的 key 交给 admit 判断，并返回读取时的 revision。
+func (q *etcdQueue) ready(ctx context.Context, myRev int64, admit func(ahead []string) bool) (bool, int64, error) {
+	resp, err := q.client.Get(ctx, q.pfx+"/",
+		clientv3.WithPrefix(),
+		clientv3.WithMaxCreateRev(myRev-1),
+		clientv3.WithKeysOnly(),
+	)
+	if err != nil {
+		return false, 0, err
+	}
+	ahead := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		ahead = append(ahead, strings.TrimPrefix(string(kv.Key), q.pfx+"/"))
+	}
+	return admit(ahead), resp.Header.Revision, nil
+}
+
+// waitDelete 等待 rev 之后 pfx 下出现删除事件（有持有者释放或租约过期）。
+func (q *etcdQueue) waitDelete(ctx context.Context, session *concurrency.Session, rev int64) error {
+	wctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+	wch := q.client.Watch(wctx, q.pfx+"/",
+		clientv3.WithPrefix(),
+		clientv3.WithRev(rev+1),
+		clientv3.WithFilterPut(),
+	)
+	select {
+	case wr, ok := <-wch:
+		if !ok {
+			return ctx.Err()
+		}
+		return wr.Err()
+	case <-session.Done():
+		return concurrency.ErrSessionExpired
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// hold 开启持有期，并在 Session 失效时结束持有期。
+func (q *etcdQueue) hold(ctx context.Context, session *concurrency.Session) {
+	l := q.acquired(0)
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		select {
+		case <-session.Done():
+			l.end()
+		case <-l.done:
+		}
+	})
+}
+
+// release 删除自己的 key 并关闭 Session，未持有时为空操作。
+func (q *etcdQueue) release(ctx context.Context) error {
+	q.released()
+	if q.session == nil {
+		return nil
+	}
+	_, err := q.client.Delete(ctx, q.key)
+	_ = q.session.Close()
+	q.session = nil
+	q.key = ""
+	return err
+}
+
+// NewRWMutex 创建一个以 key 为标识的 etcd 读写锁。
+// 读者与写者按到达顺序排队，写者不会被后到的读者饿死。
+func (p *EtcdProvider) NewRWMutex(key string) RWMutex {
+	return &etcdRWMutex{q: etcdQueue{
+		client: p.client,
+		pfx:    p.cfg.keyPrefix + key + ":rw",
+		ttl:    p.cfg.ttl,
+	}}
+}
+
+// etcdRWMutex 实现 RWMutex 接口。
+type etcdRWMutex struct {
+	q etcdQueue
+}
+
+// admitEtcdWriter 写者前面不能有任何持有者或等待者。
+func admitEtcdWriter(ahead []string) bool {
+	return len(ahead) == 0
+}
+
+// admitEtcdReader 读者前面不能有写者。
+func admitEtcdReader(ahead []string) bool {
+	for _, k := range ahead {
+		if strings.HasPrefix(k, etcdKindWrite+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *etcdRWMutex) Lock(ctx context.Context) error {
+	return m.q.acquire(ctx, etcdKindWrite, true, admitEtcdWriter)
+}
+
+func (m *etcdRWMutex) TryLock(ctx context.Context) error {
+	return m.q.acquire(ctx, etcdKindWrite, false, admitEtcdWriter)
+}
+
+func (m *etcdRWMutex) Unlock(ctx context.Context) error {
+	return m.q.release(ctx)
+}
+
+func (m *etcdRWMutex) RLock(ctx context.Context) error {
+	return m.q.acquire(ctx, etcdKindRead, true, admitEtcdReader)
+}
+
+func (m *etcdRWMutex) TryRLock(ctx context.Context) error {
+	return m.q.acquire(ctx, etcdKindRead, false, admitEtcdReader)
+}
+
+func (m *etcdRWMutex) RUnlock(ctx context.Context) error {
+	return m.q.release(ctx)
+}
+
+func (m *etcdRWMutex) Done() <-chan struct{} {
+	return m.q.Done()
+}
+
+// NewSemaphore 创建一个以 key 为标识、最多 n 个持有者的 etcd 信号量。
+// 等待者按到达顺序获得名额。
+func (p *EtcdProvider) NewSemaphore(key string, n int) Semaphore {
+	n = max(n, 1)
+	return &etcdSemaphore{
+		q: etcdQueue{
+			client: p.client,
+			pfx:    p.cfg.keyPrefix + key + ":sem",
+			ttl:    p.cfg.ttl,
+		},
+		admit: func(ahead []string) bool { return len(ahead) < n },
+	}
+}
+
+// etcdSemaphore 实现 Semaphore 接口。
+type etcdSemaphore struct {
+	q     etcdQueue
+	admit func(ahead []string) bool
+}
+
+func (s *etcdSemaphore) Lock(ctx context.Context) error {
+	return s.q.acquire(ctx, etcdKindRead, true, s.admit)
+}
+
+func (s *etcdSemaphore) TryLock(ctx context.Context) error {
+	return s.q.acquire(ctx, etcdKindRead, false, s.admit)
+}
+
+func (s *etcdSemaphore) Unlock(ctx context.Context) error {
+	return s.q.release(ctx)
+}
+
+func (s *etcdSemaphore) Done() <-chan struct{} {
+	return s.q.Done()
+}
@@ -0,0 +1,140 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// closedChan 是一个已关闭的 channel，未持有锁时 Done 返回它。
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// lease 表示一次成功加锁后的持有期，done 在锁被释放或丢失时关闭（只关闭一次）。
+type lease struct {
+	done  chan struct{}
+	once  sync.Once
+	token int64 // 本次持锁的 fencing token
+}
+
+func newLease(token int64) *lease {
+	return &lease{done: make(chan struct{}), token: token}
+}
+
+// end 结束持有期，可重复调用。
+func (l *lease) end() {
+	l.once.Do(func() { close(l.done) })
+}
+
+// holder 记录当前的持有期，嵌入各后端的 Mutex / RWMutex / Semaphore 实现以提供 Done / Token 方法。
+type holder struct {
+	mu  sync.Mutex
+	cur *lease
+}
+
+// acquired 在加锁成功后调用，开启以 token 为 fencing token 的新持有期。
+func (h *holder) acquired(token int64) *lease {
+	l := newLease(token)
+	h.mu.Lock()
+	h.cur = l
+	h.mu.Unlock()
+	return l
+}
+
+// released 结束当前持有期。
+func (h *holder) released() {
+	h.mu.Lock()
+	l := h.cur
+	h.cur = nil
+	h.mu.Unlock()
+	if l != nil {
+		l.end()
+	}
+}
+
+// Done 返回当前持有期的 channel，锁被 Unlock 释放或丢失（租约过期、续期失败）时关闭。
+// 未持有锁时返回已关闭的 channel。
+func (h *holder) Done() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cur == nil {
+		return closedChan
+	}
+	return h.cur.done
+}
+
+// Token 返回当前持有期的 fencing token，未持有锁时返回 0。
+func (h *holder) Token() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cur == nil {
+		return 0
+	}
+	return h.cur.token
+}
+
+// keepAlive 跟踪持有期 l 直到其结束，until 为锁的到期时刻：
+//   - interval <= 0 时不续期，到期即判定锁丢失；
+//   - 否则每隔 interval 调用 extend 续期，extend 返回新的到期时刻。续期失败但锁尚未
+//     到期时（如网络抖动）下个周期再试，直到到期或 extend 返回 ErrLeaseLost 时判定锁丢失。
+//
+// 判定锁丢失时结束 l（关闭 Done）。
+func keepAlive(l *lease, until time.Time, interval time.Duration, extend func() (time.Time, error)) {
+	if interval <= 0 {
+		timer := time.NewTimer(time.Until(until))
+		defer timer.Stop()
+		select {
+		case <-l.done:
+		case <-timer.C:
+			l.end()
+		}
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			next, err := extend()
+			if err == nil {
+				until = next
+				continue
+			}
+			if errors.Is(err, ErrLeaseLost) || !time.Now().Before(until) {
+				l.end()
+				return
+			}
+		}
+	}
+}
+
+// retry 重复调用 try，两次之间等待 delay，直到成功、共尝试 tries 次仍失败
+// （返回 ErrLockFailed）或 ctx 被取消。
+func retry(ctx context.Context, tries int, delay time.Duration, try func(ctx context.Context) (bool, error)) error {
+	for i := 1; ; i++ {
+		ok, err := try(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if i >= tries {
+			return ErrLockFailed
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -3,12 +3,31 @@ package dlock
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// MemoryOption 配置 MemoryProvider 的选项函数。
+type MemoryOption func(*memoryProviderConfig)
+
+type memoryProviderConfig struct {
+	waitAlert time.Duration // Lock 等待超过该时长时告警，0 表示不告警
+}
+
+// WithMemoryWaitAlert 设置 Lock 等待告警阈值：等待超过 d 时通过 notify 发送告警（默认不告警）。
+func WithMemoryWaitAlert(d time.Duration) MemoryOption {
+	return func(c *memoryProviderConfig) { c.waitAlert = d }
+}
+
 // MemoryProvider 是基于内存的 per-key 锁工厂，仅在单进程内有效。
 type MemoryProvider struct {
-	mu    sync.Mutex
-	locks map[string]*memoryLock
+	mu      sync.Mutex
+	locks   map[string]*memoryLock
+	holders map[string]HolderInfo    // 互斥锁当前持有者，仅在被持有时存在
+	gates   map[string]*memoryGate   // 读写锁与信号量的状态，仅在被持有时存在
+	board   map[string]*memoryLeader // 各选举名下当前的 leader
+	fence   atomic.Int64             // fencing token 计数器，所有 key 共享，保证单调递增
+	mon     *monitor
 }
 
 // memoryLock 是一个引用计数的 per-key 锁内核，多个 memoryMutex 可共享同一个。
@@ -19,19 +38,35 @@ type memoryLock struct {
 
 // memoryMutex 实现 Mutex 接口，绑定到一个 memoryLock。
 type memoryMutex struct {
+	holder
 	p   *MemoryProvider
 	key string
+	mon *monitor
 }
 
 // NewMemoryProvider 返回一个内存锁工厂。
-func NewMemoryProvider() *MemoryProvider {
-	return &MemoryProvider{
-		locks: make(map[string]*memoryLock),
+func NewMemoryProvider(opts ...MemoryOption) *MemoryProvider {
+	var cfg memoryProviderConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	p := &MemoryProvider{
+		locks:   make(map[string]*memoryLock),
+		holders: make(map[string]HolderInfo),
+		gates:   make(map[string]*memoryGate),
+		board:   make(map[string]*memoryLeader),
 	}
+	p.mon = &monitor{waitAlert: cfg.waitAlert, inspect: p.Inspect}
+	return p
 }
 
 // NewMutex 创建一个以 key 为标识的内存互斥锁。
 func (p *MemoryProvider) NewMutex(key string) Mutex {
+	return p.newMutex(key, p.mon)
+}
+
+// newMutex 创建互斥锁，mon 为 nil 时不计入统计（选主内部使用）。
+func (p *MemoryProvider) newMutex(key string, mon *monitor) *memoryMutex {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -42,7 +77,34 @@ func (p *MemoryProvider) NewMutex(key string) Mutex {
 	}
 	lk.refs++
 
-	return &memoryMutex{p: p, key: key}
+	return &memoryMutex{p: p, key: key, mon: mon}
+}
+
+// Inspect 返回 key 对应互斥锁的当前持有者，无人持有时返回 ErrNotLocked。
+func (p *MemoryProvider) Inspect(_ context.Context, key string) (*HolderInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info, ok := p.holders[key]
+	if !ok {
+		return nil, ErrNotLocked
+	}
+	return &info, nil
+}
+
+// Stats 返回互斥锁的加锁统计。
+func (p *MemoryProvider) Stats() Stats {
+	return p.mon.snapshot()
+}
+
+// setHolder 登记 key 的持有者，info 为 nil 时清除。
+func (p *MemoryProvider) setHolder(key string, info *HolderInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if info == nil {
+		delete(p.holders, key)
+		return
+	}
+	p.holders[key] = *info
 }
 
 // getLock 获取 key 对应的 memoryLock（调用方需确保 key 存在）。
@@ -68,13 +130,16 @@ func (p *MemoryProvider) release(key string) {
 }
 
 // Lock 阻塞式获取锁，支持通过 ctx 取消。
-func (m *memoryMutex) Lock(ctx context.Context) error {
+func (m *memoryMutex) Lock(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { m.mon.waited(ctx, m.key, start, err) }()
 	lk := m.p.getLock(m.key)
 	if lk == nil {
 		return ErrLockFailed
 	}
 	select {
 	case lk.ch <- struct{}{}:
+		m.hold(ctx)
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
@@ -82,27 +147,38 @@ func (m *memoryMutex) Lock(ctx context.Context) error {
 }
 
 // TryLock 非阻塞式尝试获取锁，失败返回 ErrLockFailed。
-func (m *memoryMutex) TryLock(_ context.Context) error {
+func (m *memoryMutex) TryLock(ctx context.Context) (err error) {
+	defer func() { m.mon.tried(err) }()
 	lk := m.p.getLock(m.key)
 	if lk == nil {
 		return ErrLockFailed
 	}
 	select {
 	case lk.ch <- struct{}{}:
+		m.hold(ctx)
 		return nil
 	default:
 		return ErrLockFailed
 	}
 }
 
+// hold 领取 fencing token 并登记持有者。
+func (m *memoryMutex) hold(ctx context.Context) {
+	m.acquired(m.p.fence.Add(1))
+	info := newHolderInfo(ctx)
+	m.p.setHolder(m.key, &info)
+}
+
 // Unlock 释放锁。
 func (m *memoryMutex) Unlock(_ context.Context) error {
+	m.released()
 	lk := m.p.getLock(m.key)
 	if lk == nil {
 		return nil
 	}
 	select {
 	case <-lk.ch:
+		m.p.setHolder(m.key, nil)
 	default:
 	}
 	return nil
@@ -0,0 +1,84 @@
+package dlock
+
+import "context"
+
+// electionKeySuffix 是选举底层互斥锁 key 的后缀。
+const electionKeySuffix = ":election"
+
+// memoryLeader 记录一个选举名下当前 leader 的 value，变化时关闭并替换 wake。
+type memoryLeader struct {
+	value string
+	wake  chan struct{}
+}
+
+// leaderOf 返回 name 对应的 memoryLeader（调用方需持有 p.mu）。
+func (p *MemoryProvider) leaderOf(name string) *memoryLeader {
+	ld, ok := p.board[name]
+	if !ok {
+		ld = &memoryLeader{wake: make(chan struct{})}
+		p.board[name] = ld
+	}
+	return ld
+}
+
+func (p *MemoryProvider) setLeader(name, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ld := p.leaderOf(name)
+	ld.value = value
+	close(ld.wake)
+	ld.wake = make(chan struct{})
+}
+
+// NewElection 创建一个以 name 为标识的内存选主实例，主要用于测试。
+func (p *MemoryProvider) NewElection(name string, opts ...ElectionOption) Election {
+	return &memoryElection{
+		leadership: leadership{cfg: newElectionConfig(opts)},
+		p:          p,
+		name:       name,
+		mu:         p.newMutex(name+electionKeySuffix, nil),
+	}
+}
+
+// memoryElection 以内存互斥锁竞选，当选后只能通过 Resign 放弃。
+type memoryElection struct {
+	leadership
+	p    *MemoryProvider
+	name string
+	mu   Mutex
+}
+
+func (e *memoryElection) Campaign(ctx context.Context, value string) error {
+	if err := e.mu.Lock(ctx); err != nil {
+		return err
+	}
+	e.p.setLeader(e.name, value)
+	e.elected(ctx, nil, e.mu.Token())
+	return nil
+}
+
+func (e *memoryElection) Resign(ctx context.Context) error {
+	if e.Token() == 0 {
+		return nil
+	}
+	e.p.setLeader(e.name, "")
+	e.released()
+	return e.mu.Unlock(ctx)
+}
+
+func (e *memoryElection) Leader(_ context.Context) (string, error) {
+	e.p.mu.Lock()
+	defer e.p.mu.Unlock()
+	if v := e.p.leaderOf(e.name).value; v != "" {
+		return v, nil
+	}
+	return "", ErrNoLeader
+}
+
+func (e *memoryElection) Observe(ctx context.Context) <-chan string {
+	return observe(ctx, e.Leader, func() <-chan struct{} {
+		e.p.mu.Lock()
+		defer e.p.mu.Unlock()
+		return e.p.leaderOf(e.name).wake
+	})
+}
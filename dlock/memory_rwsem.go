@@ -0,0 +1,200 @@
+package dlock
+
+import "context"
+
+// memoryGate 是内存读写锁 / 信号量的共享状态，由 MemoryProvider.mu 保护。
+// 状态每次变化时关闭并替换 wake，唤醒所有等待者重新检查。
+type memoryGate struct {
+	shared int  // 读锁或信号量名额的持有数
+	writer bool // 是否有写锁持有者
+	wake   chan struct{}
+}
+
+func (g *memoryGate) idle() bool {
+	return g.shared == 0 && !g.writer
+}
+
+// tryGate 尝试以 admit 规则占用 gate，失败时返回用于等待状态变化的 channel。
+func (p *MemoryProvider) tryGate(key string, admit func(g *memoryGate) bool) (<-chan struct{}, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	g, ok := p.gates[key]
+	if !ok {
+		g = &memoryGate{wake: make(chan struct{})}
+		p.gates[key] = g
+	}
+	if admit(g) {
+		return nil, true
+	}
+	return g.wake, false
+}
+
+// waitGate 阻塞直到 admit 成功或 ctx 被取消。
+func (p *MemoryProvider) waitGate(ctx context.Context, key string, admit func(g *memoryGate) bool) error {
+	for {
+		wake, ok := p.tryGate(key, admit)
+		if ok {
+			return nil
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// leaveGate 执行 leave 归还占用并唤醒等待者，gate 空闲时从 map 中移除。
+func (p *MemoryProvider) leaveGate(key string, leave func(g *memoryGate)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	g, ok := p.gates[key]
+	if !ok {
+		return
+	}
+	leave(g)
+	close(g.wake)
+	if g.idle() {
+		delete(p.gates, key)
+	} else {
+		g.wake = make(chan struct{})
+	}
+}
+
+// NewRWMutex 创建一个以 key 为标识的内存读写锁。
+func (p *MemoryProvider) NewRWMutex(key string) RWMutex {
+	return &memoryRWMutex{p: p, key: "rw:" + key}
+}
+
+// memoryRWMutex 实现 RWMutex 接口。读锁优先：持续有读者时写者可能一直等待。
+type memoryRWMutex struct {
+	holder
+	p       *MemoryProvider
+	key     string
+	reading bool
+	writing bool
+}
+
+func admitWriter(g *memoryGate) bool {
+	if g.idle() {
+		g.writer = true
+		return true
+	}
+	return false
+}
+
+func admitReader(g *memoryGate) bool {
+	if !g.writer {
+		g.shared++
+		return true
+	}
+	return false
+}
+
+func (m *memoryRWMutex) Lock(ctx context.Context) error {
+	if err := m.p.waitGate(ctx, m.key, admitWriter); err != nil {
+		return err
+	}
+	m.writing = true
+	m.acquired(0)
+	return nil
+}
+
+func (m *memoryRWMutex) TryLock(_ context.Context) error {
+	if _, ok := m.p.tryGate(m.key, admitWriter); !ok {
+		return ErrLockFailed
+	}
+	m.writing = true
+	m.acquired(0)
+	return nil
+}
+
+func (m *memoryRWMutex) Unlock(_ context.Context) error {
+	if !m.writing {
+		return nil
+	}
+	m.writing = false
+	m.released()
+	m.p.leaveGate(m.key, func(g *memoryGate) { g.writer = false })
+	return nil
+}
+
+func (m *memoryRWMutex) RLock(ctx context.Context) error {
+	if err := m.p.waitGate(ctx, m.key, admitReader); err != nil {
+		return err
+	}
+	m.reading = true
+	m.acquired(0)
+	return nil
+}
+
+func (m *memoryRWMutex) TryRLock(_ context.Context) error {
+	if _, ok := m.p.tryGate(m.key, admitReader); !ok {
+		return ErrLockFailed
+	}
+	m.reading = true
+	m.acquired(0)
+	return nil
+}
+
+func (m *memoryRWMutex) RUnlock(_ context.Context) error {
+	if !m.reading {
+		return nil
+	}
+	m.reading = false
+	m.released()
+	m.p.leaveGate(m.key, func(g *memoryGate) { g.shared-- })
+	return nil
+}
+
+// NewSemaphore 创建一个以 key 为标识、最多 n 个持有者的内存信号量。
+func (p *MemoryProvider) NewSemaphore(key string, n int) Semaphore {
+	return &memorySemaphore{p: p, key: "sem:" + key, n: max(n, 1)}
+}
+
+// memorySemaphore 实现 Semaphore 接口。
+type memorySemaphore struct {
+	holder
+	p    *MemoryProvider
+	key  string
+	n    int
+	held bool
+}
+
+func (s *memorySemaphore) admit(g *memoryGate) bool {
+	if g.shared < s.n {
+		g.shared++
+		return true
+	}
+	return false
+}
+
+func (s *memorySemaphore) Lock(ctx context.Context) error {
+	if err := s.p.waitGate(ctx, s.key, s.admit); err != nil {
+		return err
+	}
+	s.held = true
+	s.acquired(0)
+	return nil
+}
+
+func (s *memorySemaphore) TryLock(_ context.Context) error {
+	if _, ok := s.p.tryGate(s.key, s.admit); !ok {
+		return ErrLockFailed
+	}
+	s.held = true
+	s.acquired(0)
+	return nil
+}
+
+func (s *memorySemaphore) Unlock(_ context.Context) error {
+	if !s.held {
+		return nil
+	}
+	s.held = false
+	s.released()
+	s.p.leaveGate(s.key, func(g *memoryGate) { g.shared-- })
+	return nil
+}
@@ -0,0 +1,186 @@
+package dlock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cotary/go-lib/common/appctx"
+	"github.com/Cotary/go-lib/common/coroutines"
+	"github.com/Cotary/go-lib/common/defined"
+	"github.com/Cotary/go-lib/notify"
+)
+
+// ErrNotLocked 锁当前无人持有（Inspect 返回）。
+var ErrNotLocked = errors.New("dlock: lock is not held")
+
+// HolderInfo 描述互斥锁的当前持有者，加锁成功时与锁一同写入，随锁释放或过期。
+type HolderInfo struct {
+	Server     string    `json:"server"`               // 持有者的服务名（appctx.ServerName）
+	RequestID  string    `json:"request_id,omitempty"` // 加锁时 ctx 中的请求 ID
+	AcquiredAt time.Time `json:"acquired_at"`          // 获得锁的时间
+}
+
+func (h HolderInfo) String() string {
+	return fmt.Sprintf("server=%s request_id=%s acquired_at=%s", h.Server, h.RequestID, h.AcquiredAt.Format(time.RFC3339))
+}
+
+func newHolderInfo(ctx context.Context) HolderInfo {
+	info := HolderInfo{Server: appctx.ServerName(), AcquiredAt: time.Now()}
+	if v := ctx.Value(defined.RequestID); v != nil {
+		info.RequestID = fmt.Sprint(v)
+	}
+	return info
+}
+
+// holderRecord 是持有者信息的存储格式，Owner 标识写入者，释放时只删除自己写入的记录。
+type holderRecord struct {
+	Owner string `json:"owner,omitempty"`
+	HolderInfo
+}
+
+func encodeHolder(ctx context.Context, owner string) string {
+	data, _ := json.Marshal(holderRecord{Owner: owner, HolderInfo: newHolderInfo(ctx)})
+	return string(data)
+}
+
+func decodeHolder(data string) (*HolderInfo, error) {
+	var rec holderRecord
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return nil, fmt.Errorf("dlock: decode holder: %w", err)
+	}
+	return &rec.HolderInfo, nil
+}
+
+// Stats 是 Provider 互斥锁加锁情况的快照（自创建以来累计）。
+type Stats struct {
+	Attempts  uint64        // Lock / TryLock 调用次数
+	Acquired  uint64        // 成功获得锁的次数
+	Contended uint64        // 因锁被占用而失败的次数（TryLock / Lock 返回 ErrLockFailed）
+	Waits     uint64        // Lock 调用次数
+	WaitTime  time.Duration // Lock 的累计等待时间（含失败的等待）
+	MaxWait   time.Duration // Lock 的最长单次等待时间
+	SlowWaits uint64        // 等待时间超过告警阈值的次数
+}
+
+// AverageWait 返回 Lock 的平均等待时间。
+func (s Stats) AverageWait() time.Duration {
+	if s.Waits == 0 {
+		return 0
+	}
+	return s.WaitTime / time.Duration(s.Waits)
+}
+
+// alertCooldown 是同一 key 两次等待告警之间的最小间隔。
+const alertCooldown = time.Minute
+
+// monitor 统计 Provider 的加锁情况，并在 Lock 等待超过阈值时通过 notify 告警。
+// nil monitor 不做任何统计（选主等内部使用的锁）。
+type monitor struct {
+	waitAlert time.Duration // 0 表示不告警
+	inspect   func(ctx context.Context, key string) (*HolderInfo, error)
+
+	attempts  atomic.Uint64
+	acquired  atomic.Uint64
+	contended atomic.Uint64
+	waits     atomic.Uint64
+	waitNanos atomic.Int64
+	maxWait   atomic.Int64
+	slowWaits atomic.Uint64
+
+	alertMu   sync.Mutex
+	lastAlert map[string]time.Time // key → 上次告警时间，只保留冷却期内的记录
+}
+
+// tried 记录一次 TryLock。
+func (m *monitor) tried(err error) {
+	if m == nil {
+		return
+	}
+	m.attempts.Add(1)
+	m.count(err)
+}
+
+// waited 记录一次从 start 开始的 Lock，等待超过阈值时异步告警（附带当前持有者）。
+func (m *monitor) waited(ctx context.Context, key string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.attempts.Add(1)
+	m.count(err)
+
+	wait := time.Since(start)
+	m.waits.Add(1)
+	m.waitNanos.Add(int64(wait))
+	for {
+		cur := m.maxWait.Load()
+		if int64(wait) <= cur || m.maxWait.CompareAndSwap(cur, int64(wait)) {
+			break
+		}
+	}
+
+	if m.waitAlert <= 0 || wait < m.waitAlert {
+		return
+	}
+	m.slowWaits.Add(1)
+	if !m.allowAlert(key, time.Now()) {
+		return
+	}
+
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		holder := "unknown"
+		if info, err := m.inspect(ctx, key); err == nil {
+			holder = info.String()
+		}
+		alertErr := fmt.Errorf("dlock: waited %s for lock %q (err: %v), current holder: %s", wait, key, err, holder)
+		notify.SendErrMessage(ctx, alertErr)
+	})
+}
+
+// allowAlert 判断 key 是否已过告警冷却期，是则记录本次告警时间。
+// 同时清理已过冷却期的记录，避免 key 很多（如按用户 ID 加锁）时记录无限增长。
+func (m *monitor) allowAlert(key string, now time.Time) bool {
+	m.alertMu.Lock()
+	defer m.alertMu.Unlock()
+	if last, ok := m.lastAlert[key]; ok && now.Sub(last) < alertCooldown {
+		return false
+	}
+	for k, last := range m.lastAlert {
+		if now.Sub(last) >= alertCooldown {
+			delete(m.lastAlert, k)
+		}
+	}
+	if m.lastAlert == nil {
+		m.lastAlert = make(map[string]time.Time)
+	}
+	m.lastAlert[key] = now
+	return true
+}
+
+func (m *monitor) count(err error) {
+	switch {
+	case err == nil:
+		m.acquired.Add(1)
+	case errors.Is(err, ErrLockFailed):
+		m.contended.Add(1)
+	}
+}
+
+func (m *monitor) snapshot() Stats {
+	if m == nil {
+		return Stats{}
+	}
+	return Stats{
+		Attempts:  m.attempts.Load(),
+		Acquired:  m.acquired.Load(),
+		Contended: m.contended.Load(),
+		Waits:     m.waits.Load(),
+		WaitTime:  time.Duration(m.waitNanos.Load()),
+		MaxWait:   time.Duration(m.maxWait.Load()),
+		SlowWaits: m.slowWaits.Load(),
+	}
+}
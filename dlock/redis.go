@@ -2,9 +2,13 @@ package dlock
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/Cotary/go-lib/common/coroutines"
 	"github.com/go-redsync/redsync/v4"
 	redsyncredis "github.com/go-redsync/redsync/v4/redis"
 )
@@ -17,6 +21,9 @@ type redisProviderConfig struct {
 	tries      int
 	retryDelay time.Duration
 	keyPrefix  string
+	watchdog   time.Duration // 续期间隔，0 表示不开启看门狗
+	waitAlert  time.Duration // Lock 等待超过该时长时告警，0 表示不告警
+	fencing    bool          // 加锁时是否领取 fencing token
 }
 
 var defaultRedisConfig = redisProviderConfig{
@@ -46,10 +53,37 @@ func WithRedisKeyPrefix(prefix string) RedisOption {
 	return func(c *redisProviderConfig) { c.keyPrefix = prefix }
 }
 
+// WithRedisWatchdog 开启看门狗：持锁期间每隔 interval 自动续期一次（续到完整的 expiry），
+// 直到 Unlock 或续期失败。interval <= 0 时取 expiry/3。
+// 适用于执行时间不可预估的长任务，配合 Mutex.Done 感知锁丢失。
+func WithRedisWatchdog(interval time.Duration) RedisOption {
+	return func(c *redisProviderConfig) {
+		if interval <= 0 {
+			interval = -1 // 在 NewRedisProvider 中按最终的 expiry 计算
+		}
+		c.watchdog = interval
+	}
+}
+
+// WithRedisWaitAlert 设置 Lock 等待告警阈值：等待超过 d 时通过 notify 发送告警，
+// 告警内容包含当前持有者（默认不告警）。
+func WithRedisWaitAlert(d time.Duration) RedisOption {
+	return func(c *redisProviderConfig) { c.waitAlert = d }
+}
+
+// WithRedisFencing 开启 fencing token：每次加锁后多执行一次 Lua 脚本，从 Provider 级的
+// 计数器 "<keyPrefix>#fence" 领取 token（默认不开启，Mutex.Token 返回 0）。
+// 所有 key 共用一个计数器，token 在每个 key 上严格递增但不连续，不会为每个 key 留下计数器。
+func WithRedisFencing() RedisOption {
+	return func(c *redisProviderConfig) { c.fencing = true }
+}
+
 // RedisProvider 基于 redsync 的分布式锁工厂。
 type RedisProvider struct {
-	rs  *redsync.Redsync
-	cfg redisProviderConfig
+	rs   *redsync.Redsync
+	pool redsyncredis.Pool
+	cfg  redisProviderConfig
+	mon  *monitor
 }
 
 // NewRedisProvider 创建基于 Redis 的分布式锁工厂。
@@ -59,50 +93,219 @@ func NewRedisProvider(pool redsyncredis.Pool, opts ...RedisOption) *RedisProvide
 	for _, o := range opts {
 		o(&cfg)
 	}
-	return &RedisProvider{
-		rs:  redsync.New(pool),
-		cfg: cfg,
+	if cfg.watchdog < 0 {
+		cfg.watchdog = cfg.expiry / 3
+	}
+	p := &RedisProvider{
+		rs:   redsync.New(pool),
+		pool: pool,
+		cfg:  cfg,
 	}
+	p.mon = &monitor{waitAlert: cfg.waitAlert, inspect: p.Inspect}
+	return p
 }
 
 // NewMutex 创建一个以 key 为标识的 Redis 分布式锁。
 func (p *RedisProvider) NewMutex(key string) Mutex {
-	return &redisMutex{
-		mu: p.rs.NewMutex(
-			p.cfg.keyPrefix+key,
-			redsync.WithExpiry(p.cfg.expiry),
-			redsync.WithTries(p.cfg.tries),
-			redsync.WithRetryDelay(p.cfg.retryDelay),
-		),
+	return p.newMutex(key, p.cfg.watchdog, p.mon)
+}
+
+// newMutex 创建互斥锁，mon 为 nil 时不计入统计（选主内部使用）。
+func (p *RedisProvider) newMutex(key string, watchdog time.Duration, mon *monitor, opts ...redsync.Option) *redisMutex {
+	opts = append([]redsync.Option{
+		redsync.WithExpiry(p.cfg.expiry),
+		redsync.WithTries(p.cfg.tries),
+		redsync.WithRetryDelay(p.cfg.retryDelay),
+	}, opts...)
+	m := &redisMutex{
+		mu:        p.rs.NewMutex(p.cfg.keyPrefix+key, opts...),
+		pool:      p.pool,
+		key:       key,
+		holderKey: p.cfg.keyPrefix + key + holderKeySuffix,
+		expiry:    p.cfg.expiry,
+		watchdog:  watchdog,
+		mon:       mon,
+	}
+	if p.cfg.fencing {
+		m.fenceKey = p.cfg.keyPrefix + fenceKeyName
 	}
+	return m
+}
+
+// Inspect 返回 key 对应互斥锁的当前持有者，无人持有时返回 ErrNotLocked。
+func (p *RedisProvider) Inspect(ctx context.Context, key string) (*HolderInfo, error) {
+	conn, err := p.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	v, err := conn.Get(p.cfg.keyPrefix + key + holderKeySuffix)
+	if err != nil {
+		return nil, err
+	}
+	if v == "" {
+		return nil, ErrNotLocked
+	}
+	return decodeHolder(v)
+}
+
+// Stats 返回互斥锁的加锁统计。
+func (p *RedisProvider) Stats() Stats {
+	return p.mon.snapshot()
+}
+
+// holderKeySuffix 是持有者信息 key 的后缀，与锁使用相同的过期时间，随看门狗一同续期。
+const holderKeySuffix = ":holder"
+
+var (
+	// holderSetScript 写入持有者信息。ARGV[1] = JSON，ARGV[2] = 过期时间（毫秒）。
+	holderSetScript = newScript(1, `redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2]) return 1`)
+	// holderExtendScript 续期持有者信息。ARGV[1] = 过期时间（毫秒）。
+	holderExtendScript = newScript(1, `return redis.call('PEXPIRE', KEYS[1], ARGV[1])`)
+	// holderDelScript 仅当持有者信息由 ARGV[1] 写入时删除，避免误删下一个持有者的记录。
+	holderDelScript = newScript(1, `
+local v = redis.call('GET', KEYS[1])
+if v and cjson.decode(v).owner == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// fenceKeyName 是 Provider 级 fencing token 计数器的 key（加上 keyPrefix），计数器不设过期时间。
+const fenceKeyName = "#fence"
+
+// fenceScript 仅当锁仍由 ARGV[1] 持有时对计数器 INCR，否则返回 0。
+// KEYS[1] = 锁 key，KEYS[2] = 计数器 key。
+var fenceScript = newScript(2, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// nextToken 在确认仍持有锁的前提下原子地领取本次持锁的 fencing token。
+// 加锁后、领取前锁已过期（如进程停顿）时返回 ErrLockFailed：此时锁可能已被他人获得并
+// 领取了更小的 token，若仍领取则旧持有者会拿到更大的 token，使 fencing 失效。
+func nextToken(ctx context.Context, pool redsyncredis.Pool, lockKey, fenceKey, value string) (int64, error) {
+	token, err := evalInt(ctx, pool, fenceScript, lockKey, fenceKey, value)
+	if err != nil {
+		return 0, err
+	}
+	if token == 0 {
+		return 0, fmt.Errorf("%w: lock expired before the fencing token was issued", ErrLockFailed)
+	}
+	return token, nil
+}
+
+// newScript 创建 redsync 的 Lua 脚本，预先计算 SHA1 以便优先走 EVALSHA。
+func newScript(keyCount int, src string) *redsyncredis.Script {
+	sum := sha1.Sum([]byte(src))
+	return redsyncredis.NewScript(keyCount, src, hex.EncodeToString(sum[:]))
+}
+
+// evalInt 执行返回整数的 Lua 脚本。
+func evalInt(ctx context.Context, pool redsyncredis.Pool, script *redsyncredis.Script, keysAndArgs ...interface{}) (int64, error) {
+	conn, err := pool.Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	reply, err := conn.Eval(script, keysAndArgs...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("dlock: unexpected script reply %T", reply)
+	}
+	return n, nil
 }
 
 // redisMutex 封装 redsync.Mutex，实现 Mutex 接口。
 type redisMutex struct {
-	mu *redsync.Mutex
+	holder
+	mu        *redsync.Mutex
+	pool      redsyncredis.Pool
+	key       string
+	fenceKey  string // 开启 WithRedisFencing 时为计数器 key，否则为空
+	holderKey string
+	expiry    time.Duration
+	watchdog  time.Duration
+	mon       *monitor
+	stopped   chan struct{} // 后台跟踪协程退出时关闭
 }
 
-func (m *redisMutex) Lock(ctx context.Context) error {
-	if err := m.mu.LockContext(ctx); err != nil {
-		if errors.Is(err, redsync.ErrFailed) {
-			return ErrLockFailed
-		}
+func (m *redisMutex) Lock(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { m.mon.waited(ctx, m.key, start, err) }()
+	if err := lockError(m.mu.LockContext(ctx)); err != nil {
 		return err
 	}
-	return nil
+	return m.hold(ctx)
 }
 
-func (m *redisMutex) TryLock(ctx context.Context) error {
-	if err := m.mu.TryLockContext(ctx); err != nil {
-		if errors.Is(err, redsync.ErrFailed) {
-			return ErrLockFailed
-		}
+func (m *redisMutex) TryLock(ctx context.Context) (err error) {
+	defer func() { m.mon.tried(err) }()
+	if err := lockError(m.mu.TryLockContext(ctx)); err != nil {
 		return err
 	}
+	return m.hold(ctx)
+}
+
+// hold 领取 fencing token（开启 WithRedisFencing 时）、写入持有者信息、开启持有期并在后台跟踪锁的有效期（见 keepAlive）。
+// 领取 token 失败（含锁已过期）时释放刚拿到的锁并返回错误；持有者信息仅用于排查，写入失败不影响加锁。
+func (m *redisMutex) hold(ctx context.Context) error {
+	var token int64
+	if m.fenceKey != "" {
+		var err error
+		token, err = nextToken(ctx, m.pool, m.mu.Name(), m.fenceKey, m.mu.Value())
+		if err != nil {
+			_, _ = m.mu.UnlockContext(context.WithoutCancel(ctx))
+			return err
+		}
+	}
+	_, _ = evalInt(ctx, m.pool, holderSetScript, m.holderKey, encodeHolder(ctx, m.mu.Value()), m.expiry.Milliseconds())
+	l := m.acquired(token)
+	stopped := make(chan struct{})
+	m.stopped = stopped
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		defer close(stopped)
+		keepAlive(l, m.mu.Until(), m.watchdog, func() (time.Time, error) {
+			ok, err := m.mu.ExtendContext(ctx)
+			if err == nil && !ok {
+				err = redsync.ErrExtendFailed
+			}
+			if err == nil {
+				_, _ = evalInt(ctx, m.pool, holderExtendScript, m.holderKey, m.expiry.Milliseconds())
+			}
+			return m.mu.Until(), err
+		})
+	})
 	return nil
 }
 
+// lockError 将 redsync 的「锁已被占用」类错误统一为 ErrLockFailed，
+// 其余错误（如 Redis 不可用）原样返回。
+func lockError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var taken *redsync.ErrTaken
+	if errors.Is(err, redsync.ErrFailed) || errors.As(err, &taken) {
+		return ErrLockFailed
+	}
+	return err
+}
+
 func (m *redisMutex) Unlock(ctx context.Context) error {
+	m.released()
+	// 等待后台协程退出，避免与 redsync.Mutex 的续期并发
+	if m.stopped != nil {
+		<-m.stopped
+		m.stopped = nil
+	}
+	_, _ = evalInt(ctx, m.pool, holderDelScript, m.holderKey, m.mu.Value())
 	_, err := m.mu.UnlockContext(ctx)
 	if err != nil && errors.Is(err, redsync.ErrLockAlreadyExpired) {
 		return nil
@@ -0,0 +1,96 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"github.com/google/uuid"
+)
+
+// NewElection 创建一个以 name 为标识的 Redis 选主实例。
+// 当选后始终开启看门狗续期（未配置 WithRedisWatchdog 时间隔取 expiry/3），
+// 续期失败直到锁过期即判定失去 leader 身份。
+func (p *RedisProvider) NewElection(name string, opts ...ElectionOption) Election {
+	return &redisElection{
+		leadership: leadership{cfg: newElectionConfig(opts)},
+		p:          p,
+		key:        name + electionKeySuffix,
+	}
+}
+
+// redisElection 以 Redis 锁竞选，锁的 value 为「随机 id:value」，Leader 从中取出 value。
+type redisElection struct {
+	leadership
+	p   *RedisProvider
+	key string
+	mu  *redisMutex
+}
+
+func (e *redisElection) Campaign(ctx context.Context, value string) error {
+	watchdog := e.p.cfg.watchdog
+	if watchdog <= 0 {
+		watchdog = e.p.cfg.expiry / 3
+	}
+	mu := e.p.newMutex(e.key, watchdog, nil, redsync.WithGenValueFunc(func() (string, error) {
+		return uuid.NewString() + ":" + value, nil
+	}))
+
+	for {
+		err := mu.TryLock(ctx)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrLockFailed) {
+			return err
+		}
+		timer := time.NewTimer(e.p.cfg.retryDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	e.mu = mu
+	e.elected(ctx, mu.Done(), mu.Token())
+	return nil
+}
+
+func (e *redisElection) Resign(ctx context.Context) error {
+	if e.mu == nil {
+		return nil
+	}
+	e.released()
+	err := e.mu.Unlock(ctx)
+	e.mu = nil
+	return err
+}
+
+func (e *redisElection) Leader(ctx context.Context) (string, error) {
+	conn, err := e.p.pool.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	v, err := conn.Get(e.p.cfg.keyPrefix + e.key)
+	if err != nil {
+		return "", err
+	}
+	if _, value, ok := strings.Cut(v, ":"); ok {
+		return value, nil
+	}
+	return "", ErrNoLeader
+}
+
+// Observe 按 WithRedisRetryDelay 的间隔轮询当前 leader。
+func (e *redisElection) Observe(ctx context.Context) <-chan string {
+	return observe(ctx, e.Leader, func() <-chan struct{} {
+		ch := make(chan struct{})
+		time.AfterFunc(e.p.cfg.retryDelay, func() { close(ch) })
+		return ch
+	})
+}
@@ -0,0 +1,268 @@
+package dlock
+
+import (
+	"context"
+	"time"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	redsyncredis "github.com/go-redsync/redsync/v4/redis"
+	"github.com/google/uuid"
+)
+
+// 读写锁与信号量的持有者都带有过期时间（WithRedisExpiry），持有者崩溃后名额自动回收；
+// 开启看门狗（WithRedisWatchdog）时持有期间自动续期，语义同 Mutex。
+// 脚本内统一使用 Redis 服务器时间，避免客户端时钟偏差。
+
+// semAcquireScript 清理过期持有者后，在名额未满时登记持有者。
+// KEYS[1] = 持有者 zset（score 为过期时刻），ARGV[1] = 名额上限，ARGV[2] = 持有者 id，ARGV[3] = 过期时间（毫秒）。
+var semAcquireScript = newScript(1, `
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[3]), ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// zsetExtendScript 把仍未过期的持有者续期一个过期时间，已不在 zset 中或已过期时返回 0。
+// KEYS[1] = 持有者 zset，ARGV[1] = 持有者 id，ARGV[2] = 过期时间（毫秒）。
+var zsetExtendScript = newScript(1, `
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or tonumber(score) <= now then
+	return 0
+end
+redis.call('ZADD', KEYS[1], 'XX', now + tonumber(ARGV[2]), ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// zremScript 移除持有者。KEYS[1] = 持有者 zset，ARGV[1] = 持有者 id。
+var zremScript = newScript(1, `return redis.call('ZREM', KEYS[1], ARGV[1])`)
+
+// rwLockScript 在没有写者且没有未过期读者时登记写者。
+// KEYS[1] = 写者 key，KEYS[2] = 读者 zset，ARGV[1] = 持有者 id，ARGV[2] = 过期时间（毫秒）。
+var rwLockScript = newScript(2, `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+if redis.call('ZCARD', KEYS[2]) > 0 then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// rwRLockScript 在没有写者时登记读者，参数同 rwLockScript。
+var rwRLockScript = newScript(2, `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+redis.call('ZADD', KEYS[2], now + tonumber(ARGV[2]), ARGV[1])
+redis.call('PEXPIRE', KEYS[2], ARGV[2])
+return 1
+`)
+
+// rwUnlockScript 仅当写者仍是自己时删除写者 key。KEYS[1] = 写者 key，ARGV[1] = 持有者 id。
+var rwUnlockScript = newScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// rwExtendScript 仅当写者仍是自己时续期写者 key。
+// KEYS[1] = 写者 key，ARGV[1] = 持有者 id，ARGV[2] = 过期时间（毫秒）。
+var rwExtendScript = newScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// redisLease 记录读写锁 / 信号量的持有期，并在后台跟踪其有效期（见 keepAlive）。
+type redisLease struct {
+	holder
+	stopped chan struct{} // 后台跟踪协程退出时关闭
+}
+
+// hold 开启持有期，until 为持有者的到期时刻；看门狗开启时通过 extend 续期，
+// extend 返回 0 表示已不再持有。
+func (h *redisLease) hold(ctx context.Context, p *RedisProvider, until time.Time, extend func(ctx context.Context) (int64, error)) {
+	l := h.acquired(0)
+	stopped := make(chan struct{})
+	h.stopped = stopped
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(ctx context.Context) {
+		defer close(stopped)
+		keepAlive(l, until, p.cfg.watchdog, func() (time.Time, error) {
+			next := time.Now().Add(p.cfg.expiry)
+			n, err := extend(ctx)
+			if err == nil && n == 0 {
+				err = ErrLeaseLost
+			}
+			return next, err
+		})
+	})
+}
+
+// release 结束持有期并等待后台协程退出，避免与续期并发。
+func (h *redisLease) release() {
+	h.released()
+	if h.stopped != nil {
+		<-h.stopped
+		h.stopped = nil
+	}
+}
+
+// retry 按 WithRedisTries / WithRedisRetryDelay 重复调用 try。
+func (p *RedisProvider) retry(ctx context.Context, try func(ctx context.Context) (bool, error)) error {
+	return retry(ctx, p.cfg.tries, p.cfg.retryDelay, try)
+}
+
+// NewRWMutex 创建一个以 key 为标识的 Redis 读写锁。
+// 写者与读者 key 使用相同的 hash tag，保证在 Redis Cluster 中位于同一 slot。
+func (p *RedisProvider) NewRWMutex(key string) RWMutex {
+	base := p.cfg.keyPrefix + "{" + key + "}"
+	return &redisRWMutex{
+		p:         p,
+		writerKey: base + ":w",
+		readerKey: base + ":r",
+		id:        uuid.NewString(),
+	}
+}
+
+// redisRWMutex 实现 RWMutex 接口。读锁优先：持续有读者时写者可能一直等待。
+type redisRWMutex struct {
+	redisLease
+	p         *RedisProvider
+	writerKey string
+	readerKey string
+	id        string
+}
+
+// eval 执行加锁脚本，成功时开启持有期，extend 用于续期。
+func (m *redisRWMutex) eval(script *redsyncredis.Script, extend func(ctx context.Context) (int64, error)) func(ctx context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		until := time.Now().Add(m.p.cfg.expiry)
+		n, err := evalInt(ctx, m.p.pool, script, m.writerKey, m.readerKey, m.id, m.p.cfg.expiry.Milliseconds())
+		if err != nil || n != 1 {
+			return false, err
+		}
+		m.hold(ctx, m.p, until, extend)
+		return true, nil
+	}
+}
+
+func (m *redisRWMutex) extendWriter(ctx context.Context) (int64, error) {
+	return evalInt(ctx, m.p.pool, rwExtendScript, m.writerKey, m.id, m.p.cfg.expiry.Milliseconds())
+}
+
+func (m *redisRWMutex) extendReader(ctx context.Context) (int64, error) {
+	return evalInt(ctx, m.p.pool, zsetExtendScript, m.readerKey, m.id, m.p.cfg.expiry.Milliseconds())
+}
+
+func (m *redisRWMutex) Lock(ctx context.Context) error {
+	return m.p.retry(ctx, m.eval(rwLockScript, m.extendWriter))
+}
+
+func (m *redisRWMutex) TryLock(ctx context.Context) error {
+	ok, err := m.eval(rwLockScript, m.extendWriter)(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockFailed
+	}
+	return nil
+}
+
+func (m *redisRWMutex) Unlock(ctx context.Context) error {
+	m.release()
+	_, err := evalInt(ctx, m.p.pool, rwUnlockScript, m.writerKey, m.id)
+	return err
+}
+
+func (m *redisRWMutex) RLock(ctx context.Context) error {
+	return m.p.retry(ctx, m.eval(rwRLockScript, m.extendReader))
+}
+
+func (m *redisRWMutex) TryRLock(ctx context.Context) error {
+	ok, err := m.eval(rwRLockScript, m.extendReader)(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockFailed
+	}
+	return nil
+}
+
+func (m *redisRWMutex) RUnlock(ctx context.Context) error {
+	m.release()
+	_, err := evalInt(ctx, m.p.pool, zremScript, m.readerKey, m.id)
+	return err
+}
+
+// NewSemaphore 创建一个以 key 为标识、最多 n 个持有者的 Redis 信号量。
+func (p *RedisProvider) NewSemaphore(key string, n int) Semaphore {
+	return &redisSemaphore{
+		p:   p,
+		key: p.cfg.keyPrefix + key + ":sem",
+		n:   max(n, 1),
+		id:  uuid.NewString(),
+	}
+}
+
+// redisSemaphore 实现 Semaphore 接口。
+type redisSemaphore struct {
+	redisLease
+	p   *RedisProvider
+	key string
+	n   int
+	id  string
+}
+
+func (s *redisSemaphore) acquire(ctx context.Context) (bool, error) {
+	until := time.Now().Add(s.p.cfg.expiry)
+	n, err := evalInt(ctx, s.p.pool, semAcquireScript, s.key, s.n, s.id, s.p.cfg.expiry.Milliseconds())
+	if err != nil || n != 1 {
+		return false, err
+	}
+	s.hold(ctx, s.p, until, s.extend)
+	return true, nil
+}
+
+func (s *redisSemaphore) extend(ctx context.Context) (int64, error) {
+	return evalInt(ctx, s.p.pool, zsetExtendScript, s.key, s.id, s.p.cfg.expiry.Milliseconds())
+}
+
+func (s *redisSemaphore) Lock(ctx context.Context) error {
+	return s.p.retry(ctx, s.acquire)
+}
+
+func (s *redisSemaphore) TryLock(ctx context.Context) error {
+	ok, err := s.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockFailed
+	}
+	return nil
+}
+
+func (s *redisSemaphore) Unlock(ctx context.Context) error {
+	s.release()
+	_, err := evalInt(ctx, s.p.pool, zremScript, s.key, s.id)
+	return err
+}
@@ -0,0 +1,95 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// heldLock 标识一把已持有的锁：同一 key 在不同 Provider 下是不同的锁。
+type heldLock struct {
+	p   Provider
+	key string
+}
+
+// heldLocksKey 用于在 context 中存储已持有的锁集合
+type heldLocksKey struct{}
+
+// getHeldLocks 从 context 中获取已持有的锁集合
+func getHeldLocks(ctx context.Context) map[heldLock]bool {
+	if locks, ok := ctx.Value(heldLocksKey{}).(map[heldLock]bool); ok {
+		return locks
+	}
+	return nil
+}
+
+// withHeldLocks 将 p 上的 keys 添加到 context 的已持有锁集合中
+func withHeldLocks(ctx context.Context, p Provider, keys []string) context.Context {
+	oldLocks := getHeldLocks(ctx)
+	// 创建新的 map，避免修改原有的
+	newLocks := make(map[heldLock]bool, len(oldLocks)+len(keys))
+	for k, v := range oldLocks {
+		newLocks[k] = v
+	}
+	for _, key := range keys {
+		newLocks[heldLock{p: p, key: key}] = true
+	}
+	return context.WithValue(ctx, heldLocksKey{}, newLocks)
+}
+
+// UnlockFunc 释放 Lock / LockAll 获取的锁。
+type UnlockFunc func(ctx context.Context) error
+
+// Lock 在 p 上阻塞获取 key 的锁，返回记录了持锁信息的 ctx 与释放函数。
+// 同一调用链的嵌套调用应使用返回的 ctx：ctx 中已持有 p 上的同一 key 时视为重入，
+// 直接返回而不再加锁，此时释放函数为空操作，锁由最外层负责释放。
+//
+//	ctx, unlock, err := dlock.Lock(ctx, p, "account:1")
+//	if err != nil { ... }
+//	defer unlock(ctx)
+func Lock(ctx context.Context, p Provider, key string) (context.Context, UnlockFunc, error) {
+	return LockAll(ctx, p, key)
+}
+
+// LockAll 在 p 上获取多个 key 的锁，要么全部成功，要么全部失败：
+// key 去重后按字典序依次加锁，保证不同调用方以相同顺序加锁、避免死锁；
+// 任一 key 加锁失败时按相反顺序释放已获取的锁并返回错误。
+// 与 Lock 一样支持重入，ctx 中已持有的 key 会被跳过。加锁失败时返回的释放函数为 nil。
+//
+//	// 转账：无论 from/to 的先后，两个账户都以相同顺序加锁
+//	ctx, unlock, err := dlock.LockAll(ctx, p, "account:"+from, "account:"+to)
+//	if err != nil { ... }
+//	defer unlock(ctx)
+func LockAll(ctx context.Context, p Provider, keys ...string) (context.Context, UnlockFunc, error) {
+	held := getHeldLocks(ctx)
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		return held[heldLock{p: p, key: key}]
+	})
+
+	locked := make([]Mutex, 0, len(keys))
+	unlock := func(ctx context.Context) error {
+		var errs []error
+		for i := len(locked) - 1; i >= 0; i-- {
+			if err := locked[i].Unlock(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, key := range keys {
+		m := p.NewMutex(key)
+		if err := m.Lock(ctx); err != nil {
+			_ = unlock(context.WithoutCancel(ctx))
+			return ctx, nil, err
+		}
+		locked = append(locked, m)
+	}
+	if len(keys) == 0 {
+		return ctx, unlock, nil
+	}
+	return withHeldLocks(ctx, p, keys), unlock, nil
+}
@@ -0,0 +1,288 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	"github.com/Cotary/go-lib/common/utils"
+	"github.com/Cotary/go-lib/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// 分布式 SingleRun。
+//
+// utils.Manager.SingleRun 只在单进程内生效。Runner 保持相同的 API（MustWait / NoWait /
+// 超时等待、RunInfo、基于 ctx 的嵌套调用），改为通过任意 Provider 的 Mutex 跨实例互斥，
+// 并把 RunInfo 写入 RunStore（通常为 Redis），其它实例可以查询与等待。
+
+// RunStore 保存 SingleRun 的运行状态。Begin / End 只在持锁期间调用。
+type RunStore interface {
+	// Begin 标记 key 开始运行：IsRunning = true、StartTime = 当前时间、RunCount + 1，返回更新后的状态。
+	Begin(ctx context.Context, key string) (utils.RunInfo, error)
+	// End 标记 key 运行结束。
+	End(ctx context.Context, key string) error
+	// Load 返回 key 的运行状态，从未运行过时返回零值。
+	Load(ctx context.Context, key string) (utils.RunInfo, error)
+}
+
+// RunnerOption 配置 Runner 的选项函数。
+type RunnerOption func(*runnerConfig)
+
+type runnerConfig struct {
+	keyPrefix    string
+	pollInterval time.Duration
+}
+
+var defaultRunnerConfig = runnerConfig{
+	keyPrefix:    "singlerun:",
+	pollInterval: 500 * time.Millisecond,
+}
+
+// WithRunnerKeyPrefix 设置 Mutex key 前缀（默认 "singlerun:"）。
+func WithRunnerKeyPrefix(prefix string) RunnerOption {
+	return func(c *runnerConfig) { c.keyPrefix = prefix }
+}
+
+// WithRunnerPollInterval 设置 Wait 轮询运行状态、以及 Lock 重试耗尽后再次加锁的间隔（默认 500ms）。
+func WithRunnerPollInterval(d time.Duration) RunnerOption {
+	return func(c *runnerConfig) {
+		if d > 0 {
+			c.pollInterval = d
+		}
+	}
+}
+
+// Runner 是跨实例的 SingleRun 执行器。
+type Runner struct {
+	p     Provider
+	store RunStore
+	cfg   runnerConfig
+}
+
+// NewRunner 创建基于 p 加锁、store 记录运行状态的 Runner。
+//
+//	p := dlock.NewRedisProvider(pool, dlock.WithRedisWatchdog(0))
+//	runner := dlock.NewRunner(p, dlock.NewRedisRunStore(rdb))
+//	info, err := runner.SingleRun(ctx, "sync-orders", utils.NoWait, syncOrders)
+func NewRunner(p Provider, store RunStore, opts ...RunnerOption) *Runner {
+	cfg := defaultRunnerConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return &Runner{p: p, store: store, cfg: cfg}
+}
+
+// SingleRun 确保对于给定的 key，f 在所有实例中同一时刻只运行一个，语义与
+// utils.Manager.SingleRun 一致：
+//
+//	waitTime < 0: 一直等 (utils.MustWait)
+//	waitTime = 0: 不等 (utils.NoWait)
+//	waitTime > 0: 等待指定时间
+//
+// 未等到时返回当前的 RunInfo 与 utils.ErrRunning。同一调用链（通过 ctx 传递）对同一 key
+// 的嵌套调用直接执行 f，不再加锁。f 的 panic 被转为 error 返回。
+// 运行期间锁丢失（如未开启看门狗时超过过期时间）时取消 f 的 ctx，并返回包含 ErrLeaseLost 的 error。
+func (r *Runner) SingleRun(ctx context.Context, key string, waitTime time.Duration, f func(ctx context.Context) error) (utils.RunInfo, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	lockKey := r.cfg.keyPrefix + key
+
+	if getHeldLocks(ctx)[heldLock{p: r.p, key: lockKey}] {
+		return utils.RunInfo{IsRunning: true}, f(ctx)
+	}
+
+	m := r.p.NewMutex(lockKey)
+	if err := r.acquire(ctx, m, waitTime); err != nil {
+		if errors.Is(err, ErrLockFailed) {
+			info, _ := r.Info(ctx, key)
+			return info, utils.ErrRunning
+		}
+		return utils.RunInfo{}, err
+	}
+	defer func() { _ = m.Unlock(context.WithoutCancel(ctx)) }()
+
+	info, err := r.store.Begin(ctx, key)
+	if err != nil {
+		return info, err
+	}
+	defer func() {
+		if err := r.store.End(context.WithoutCancel(ctx), key); err != nil {
+			log.WithContext(ctx).WithField("key", key).Error("dlock: SingleRun end: " + err.Error())
+		}
+	}()
+
+	// 锁在运行期间丢失（过期、续期失败）时取消 f 的 ctx，避免两个实例同时运行
+	runCtx, cancel := context.WithCancelCause(withHeldLocks(ctx, r.p, []string{lockKey}))
+	defer cancel(nil)
+	coroutines.SafeGo(context.WithoutCancel(ctx), func(context.Context) {
+		select {
+		case <-m.Done():
+			cancel(ErrLeaseLost)
+		case <-runCtx.Done():
+		}
+	})
+
+	err = coroutines.SafeFuncErr(runCtx, f)
+	if errors.Is(context.Cause(runCtx), ErrLeaseLost) {
+		return info, errors.Join(ErrLeaseLost, err)
+	}
+	return info, err
+}
+
+// acquire 按 waitTime 获取锁，未等到时返回 ErrLockFailed。
+// 后端 Lock 的重试次数有限（如 Redis 的 tries），耗尽后在等待期限内继续加锁。
+func (r *Runner) acquire(ctx context.Context, m Mutex, waitTime time.Duration) error {
+	if waitTime == utils.NoWait {
+		return m.TryLock(ctx)
+	}
+	parent := ctx
+	if waitTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, waitTime)
+		defer cancel()
+	}
+	for {
+		err := m.Lock(ctx)
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			if parent.Err() != nil {
+				return parent.Err()
+			}
+			return ErrLockFailed
+		case !errors.Is(err, ErrLockFailed):
+			return err
+		}
+		timer := time.NewTimer(r.cfg.pollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+}
+
+// Info 返回 key 的运行状态。持有者进程崩溃时 RunStore 中的 IsRunning 可能残留为 true，
+// 因此还会通过 Provider.Inspect 确认锁是否仍被持有，未被持有时按未运行返回。
+func (r *Runner) Info(ctx context.Context, key string) (utils.RunInfo, error) {
+	info, err := r.store.Load(ctx, key)
+	if err != nil || !info.IsRunning {
+		return info, err
+	}
+	if _, err := r.p.Inspect(ctx, r.cfg.keyPrefix+key); errors.Is(err, ErrNotLocked) {
+		info.IsRunning = false
+	}
+	return info, nil
+}
+
+// Wait 阻塞直到 key 不在任何实例上运行或 ctx 结束，返回最后一次查询到的运行状态。
+// 与 SingleRun(MustWait) 不同，Wait 只观察、不加锁，也不会执行任务。
+func (r *Runner) Wait(ctx context.Context, key string) (utils.RunInfo, error) {
+	for {
+		info, err := r.Info(ctx, key)
+		if err != nil || !info.IsRunning {
+			return info, err
+		}
+		timer := time.NewTimer(r.cfg.pollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return info, ctx.Err()
+		}
+	}
+}
+
+// ===== RunStore 实现 =====
+
+// MemoryRunStore 是进程内的 RunStore，配合 MemoryProvider 用于单实例或测试。
+type MemoryRunStore struct {
+	mu    sync.Mutex
+	infos map[string]utils.RunInfo
+}
+
+// NewMemoryRunStore 创建进程内的 RunStore。
+func NewMemoryRunStore() *MemoryRunStore {
+	return &MemoryRunStore{infos: make(map[string]utils.RunInfo)}
+}
+
+func (s *MemoryRunStore) Begin(_ context.Context, key string) (utils.RunInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := s.infos[key]
+	info.IsRunning = true
+	info.StartTime = time.Now()
+	info.RunCount++
+	s.infos[key] = info
+	return info, nil
+}
+
+func (s *MemoryRunStore) End(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := s.infos[key]
+	info.IsRunning = false
+	s.infos[key] = info
+	return nil
+}
+
+func (s *MemoryRunStore) Load(_ context.Context, key string) (utils.RunInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.infos[key], nil
+}
+
+// RedisRunStore 把 RunInfo 存为 Redis hash（字段 running / start / count），
+// 所有实例共享，count 永久累计。
+type RedisRunStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+// NewRedisRunStore 创建基于 Redis 的 RunStore，keyPrefix 默认 "singlerun:info:"。
+func NewRedisRunStore(client redis.UniversalClient, keyPrefix ...string) *RedisRunStore {
+	prefix := "singlerun:info:"
+	if len(keyPrefix) > 0 && keyPrefix[0] != "" {
+		prefix = keyPrefix[0]
+	}
+	return &RedisRunStore{client: client, keyPrefix: prefix}
+}
+
+// runBeginScript 原子地标记开始并累加次数。KEYS[1] = hash，ARGV[1] = 开始时间（ms）。
+var runBeginScript = redis.NewScript(`
+redis.call('HSET', KEYS[1], 'running', 1, 'start', ARGV[1])
+return redis.call('HINCRBY', KEYS[1], 'count', 1)
+`)
+
+func (s *RedisRunStore) Begin(ctx context.Context, key string) (utils.RunInfo, error) {
+	now := time.Now()
+	count, err := runBeginScript.Run(ctx, s.client, []string{s.keyPrefix + key}, now.UnixMilli()).Int64()
+	if err != nil {
+		return utils.RunInfo{}, err
+	}
+	return utils.RunInfo{IsRunning: true, StartTime: now, RunCount: count}, nil
+}
+
+func (s *RedisRunStore) End(ctx context.Context, key string) error {
+	return s.client.HSet(ctx, s.keyPrefix+key, "running", 0).Err()
+}
+
+func (s *RedisRunStore) Load(ctx context.Context, key string) (utils.RunInfo, error) {
+	fields, err := s.client.HGetAll(ctx, s.keyPrefix+key).Result()
+	if err != nil {
+		return utils.RunInfo{}, err
+	}
+	var info utils.RunInfo
+	info.IsRunning = fields["running"] == "1"
+	if ms, err := strconv.ParseInt(fields["start"], 10, 64); err == nil {
+		info.StartTime = time.UnixMilli(ms)
+	}
+	info.RunCount, _ = strconv.ParseInt(fields["count"], 10, 64)
+	return info, nil
+}
@@ -17,8 +17,8 @@ import (
 
 // ===== 定时任务启动 =====
 
-// StartScheduler 创建并启动定时任务调度器
-func StartScheduler() {
+// StartScheduler 创建并启动定时任务调度器，返回的调度器需在退出时 Stop
+func StartScheduler() *cmd.Scheduler {
 	scheduler, err := cmd.NewScheduler()
 	if err != nil {
 		panic(err)
@@ -26,6 +26,7 @@ func StartScheduler() {
 	// 注册任务（同名 id 幂等，不会重复注册）
 	_ = scheduler.AddJob("order-timeout-check", &OrderTimeoutJob{})
 	scheduler.Start()
+	return scheduler
 }
 
 // ===== 定时任务实现 =====
@@ -5,11 +5,8 @@
 package main
 
 import (
-	"context"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"time"
 
 	"myproject/config"
 	"myproject/dao"
@@ -19,12 +16,17 @@ import (
 
 	lib "go-lib"
 	"go-lib/common/coroutines"
+	"go-lib/common/lifecycle"
 	log2 "go-lib/log"
 	"go-lib/provider/HTTPServer/gin/handler"
+	"go-lib/provider/message/asyncSender"
 
 	"github.com/gin-gonic/gin"
 )
 
+// alertSender 为异步告警 Sender，未配置告警时为 nil
+var alertSender *asyncSender.AsyncSender
+
 // init 统一初始化入口，各服务遵循相同模板
 func init() {
 	// 1. 初始化服务名和环境
@@ -36,8 +38,9 @@ func init() {
 	// 3. 启动崩溃捕获（可选，推荐）
 	flush := lib.BootstrapCrashCapture()
 
-	// 4. 初始化告警 Sender（按需，此处省略具体实现）
-	// lib.InitGlobalSender(sender)
+	// 4. 初始化告警 Sender（按需，此处省略具体实现），异步发送，退出前在 main 中排空
+	// alertSender = asyncSender.NewAsyncSender(sender, 1000)
+	// lib.InitGlobalSender(alertSender)
 
 	// 5. 补报历史 crash dump
 	flush(coroutines.NewContext("crash-report"))
@@ -58,26 +61,28 @@ func main() {
 	// 注册路由
 	router.RegisterRouter(r)
 
-	// 启动定时任务
-	cmd.StartScheduler()
-
-	// 启动 HTTP 服务
-	srv := &http.Server{
+	// 各组件交给 lifecycle 统一管理，收到 SIGINT / SIGTERM 后按优先级从大到小优雅关闭：
+	// HTTP 服务 → 定时任务 → Redis / 数据库 → 告警 Sender
+	if alertSender != nil {
+		lifecycle.Append(lifecycle.Hook{Name: "notify", Priority: lifecycle.PriorityNotify, OnStop: alertSender.Close})
+	}
+	lifecycle.Append(lifecycle.Hook{Name: "redis", Priority: lifecycle.PriorityStorage, OnStop: lifecycle.CloseFunc(dao.Redis.Close)})
+	lifecycle.Append(lifecycle.Hook{Name: "gorm", Priority: lifecycle.PriorityStorage, OnStop: lifecycle.CloseFunc(model.DBDriver.Close)})
+
+	scheduler := cmd.StartScheduler()
+	lifecycle.Append(lifecycle.Hook{
+		Name:     "scheduler",
+		Priority: lifecycle.PriorityWorker,
+		Timeout:  time.Minute, // Stop 会等待运行中的任务完成
+		OnStop:   lifecycle.CloseFunc(scheduler.Stop),
+	})
+	lifecycle.Append(lifecycle.HTTPServer(&http.Server{
 		Addr:    config.Config.ServerPort,
 		Handler: r,
-	}
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			panic(err)
-		}
-	}()
-
-	// 优雅退出
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	if err := srv.Shutdown(context.Background()); err != nil {
-		log2.WithContext(context.Background()).Error("server shutdown error: " + err.Error())
+	}, lifecycle.PriorityServer))
+
+	ctx := coroutines.NewContext("lifecycle")
+	if err := lifecycle.Run(ctx); err != nil {
+		log2.WithContext(ctx).Error("lifecycle error: " + err.Error())
 	}
 }
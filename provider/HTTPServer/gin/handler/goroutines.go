@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/Cotary/go-lib/common/coroutines"
+	e "github.com/Cotary/go-lib/err"
+	"github.com/gin-gonic/gin"
+)
+
+// GoroutinesHandler 返回具名协程登记表的快照（见 coroutines.SafeGoNamed），用于排查协程泄漏。
+// 查询参数：
+//   - name：只返回该名字的统计
+//   - min_running：只列出运行时长不短于该值的协程，如 "5m"
+//
+// 快照包含请求 ID 等内部信息，应挂在内网或带鉴权的调试路由下：
+//
+//	debug := r.Group("/debug", handler.AuthMiddleware(conf))
+//	debug.GET("/goroutines", handler.GoroutinesHandler())
+func GoroutinesHandler() gin.HandlerFunc {
+	return C(func(c *gin.Context) (any, error) {
+		var minRunning time.Duration
+		if v := c.Query("min_running"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, e.NewHttpErr(e.ParamErr, err).SetData("invalid min_running: " + v)
+			}
+			minRunning = d
+		}
+
+		report := coroutines.GoroutineSnapshot(minRunning)
+		if name := c.Query("name"); name != "" {
+			groups := report.Groups[:0]
+			for _, g := range report.Groups {
+				if g.Name == name {
+					groups = append(groups, g)
+				}
+			}
+			report.Groups = groups
+		}
+		return report, nil
+	})
+}
@@ -3,15 +3,17 @@ package handler
 import (
 	"bytes"
 	"fmt"
+	"github.com/Cotary/go-lib/common/coroutines"
 	"github.com/Cotary/go-lib/log"
-	"github.com/Cotary/go-lib/notify"
 	"github.com/gin-gonic/gin"
-	"github.com/pkg/errors"
 	"net/http"
 	"os"
 	"runtime"
+	"runtime/debug"
 )
 
+// RecoveryHandler 记录带源码行的 panic 日志，并经 coroutines.ReportPanic 与
+// SafeFunc 共用同一套 CrashRecord 去重上报。
 func RecoveryHandler() gin.RecoveryFunc {
 	return func(c *gin.Context, err any) {
 		ctx := c.Request.Context()
@@ -19,7 +21,7 @@ func RecoveryHandler() gin.RecoveryFunc {
 		stackByte := stack(3)
 		fullErrStr := fmt.Sprintf("%v\n%v", errStr, string(stackByte))
 		log.WithContext(ctx).Error(fullErrStr)
-		notify.SendErrMessage(ctx, errors.New(fullErrStr))
+		coroutines.ReportPanic(ctx, err, debug.Stack())
 		c.Status(http.StatusInternalServerError)
 		c.Abort()
 	}
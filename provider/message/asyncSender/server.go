@@ -2,6 +2,7 @@ package asyncSender
 
 import (
 	"context"
+	"errors"
 
 	"github.com/Cotary/go-lib/common/coroutines"
 	"github.com/Cotary/go-lib/common/utils"
@@ -14,18 +15,27 @@ type Message struct {
 	Content *utils.OrderedMap[string, string]
 }
 
+// ErrClosed Close 之后调用 Send 时返回。
+var ErrClosed = errors.New("asyncSender: closed")
+
 type AsyncSender struct {
 	sender  message.Sender
 	message chan Message
+	queue   *utils.SafeChan[Message]
+	drained chan struct{} // 消费协程处理完队列中全部消息后关闭
 }
 
 func NewAsyncSender(sender message.Sender, bufferSize int) *AsyncSender {
+	ch := make(chan Message, bufferSize)
 	asyncSender := &AsyncSender{
 		sender:  sender,
-		message: make(chan Message, bufferSize),
+		message: ch,
+		queue:   utils.NewFromChan(ch),
+		drained: make(chan struct{}),
 	}
 	newCtx := coroutines.NewContext("messageSender")
 	coroutines.SafeGo(newCtx, func(ctx context.Context) {
+		defer close(asyncSender.drained)
 		asyncSender.consumeZMap()
 	})
 	return asyncSender
@@ -33,10 +43,24 @@ func NewAsyncSender(sender message.Sender, bufferSize int) *AsyncSender {
 
 // Send 异步发送消息，上下文信息应在调用方构造 zMap 时提取写入，此处不依赖 ctx 传递追踪数据
 func (a *AsyncSender) Send(_ context.Context, title string, zMap *utils.OrderedMap[string, string]) error {
-	a.message <- Message{Title: title, Content: zMap}
+	if !a.queue.Send(Message{Title: title, Content: zMap}) {
+		return ErrClosed
+	}
 	return nil
 }
 
+// Close 停止接收新消息，并等待队列中已有的消息发送完毕或 ctx 结束。
+// 可作为 lifecycle 的 OnStop 使用，应在其它组件之后关闭，以免丢失它们的停止告警。
+func (a *AsyncSender) Close(ctx context.Context) error {
+	a.queue.Close()
+	select {
+	case <-a.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (a *AsyncSender) consumeZMap() {
 	ctx := coroutines.NewContext("messageSender")
 	coroutines.ConcurrentProcessorChan(ctx, 10, a.message, func(ctx context.Context, msg Message) {